@@ -0,0 +1,305 @@
+// Prints the Photon messages of a pcap or pcapng capture, one per line.
+//
+//	photon_dump [-port 5056] [-peer 7] [-event 1] [-op 2] [-protocol 18] [-gap-timeout 5s] [-json] [-schema schema.json] capture.pcap
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/pcapgo"
+	photon "github.com/hmadison/photon_spectator"
+)
+
+// Leaves a filter unset
+const unset = -1
+
+// Settings of the stream messages are decoded with
+type config struct {
+	protocol   uint8
+	gapTimeout time.Duration
+}
+
+type filter struct {
+	port      int
+	peer      int
+	eventCode int
+	opCode    int
+}
+
+type printer func(photon.Message) error
+
+func main() {
+	var f filter
+
+	flag.IntVar(&f.port, "port", unset, "only print messages sent from or to `port`")
+	flag.IntVar(&f.peer, "peer", unset, "only print messages with the PeerID `id`")
+	flag.IntVar(&f.eventCode, "event", unset, "only print events with the event `code`")
+	flag.IntVar(&f.opCode, "op", unset, "only print operation requests and responses with the operation `code`")
+	protocol := flag.Int("protocol", photon.Protocol16, "serialization `protocol` of the messages, 16 or 18")
+	gapTimeout := flag.Duration("gap-timeout", photon.DefaultGapTimeout, "`time` to wait for a missing reliable command before skipping it")
+	jsonLines := flag.Bool("json", false, "print messages as JSON Lines, tagging values with their types")
+	schemaPath := flag.String("schema", "", "name codes and paramaters from the schema in `file`, reporting unknown codes")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] capture.pcap\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var schema *photon.Schema
+
+	if *schemaPath != "" {
+		var err error
+
+		if schema, err = photon.LoadSchema(*schemaPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	unknown := make(map[string]int)
+	output := newPrinter(os.Stdout, *jsonLines, schema, unknown)
+
+	if *protocol != photon.Protocol16 && *protocol != photon.Protocol18 {
+		fmt.Fprintf(os.Stderr, "Protocol must be 16 or 18\n")
+		os.Exit(2)
+	}
+
+	if *gapTimeout <= 0 {
+		fmt.Fprintf(os.Stderr, "Gap timeout must be positive\n")
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), config{uint8(*protocol), *gapTimeout}, f, output); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+
+	reportUnknown(os.Stderr, unknown)
+}
+
+// Returns a printer writing messages as lines of text or JSON. With a schema,
+// text lines name codes and paramaters, and messages with codes the schema
+// doesn't name are counted in unknown.
+func newPrinter(w io.Writer, jsonLines bool, schema *photon.Schema, unknown map[string]int) printer {
+	writer := photon.NewJSONLWriter(w)
+
+	return func(msg photon.Message) error {
+		if schema != nil {
+			for _, err := range schema.Validate(msg.Header, msg.Paramaters) {
+				if _, ok := err.(*photon.UnknownCodeError); ok {
+					unknown[err.Error()]++
+				}
+			}
+		}
+
+		if jsonLines {
+			return writer.Write(msg)
+		}
+
+		_, err := fmt.Fprintln(w, format(msg, schema))
+		return err
+	}
+}
+
+// Writes how many times each unknown code was seen.
+func reportUnknown(w io.Writer, unknown map[string]int) {
+	codes := make([]string, 0, len(unknown))
+
+	for code := range unknown {
+		codes = append(codes, code)
+	}
+
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		fmt.Fprintf(w, "%s seen %d times\n", code, unknown[code])
+	}
+}
+
+func run(path string, c config, f filter, output printer) error {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	source, err := packetSource(bufio.NewReader(file))
+
+	if err != nil {
+		return err
+	}
+
+	photon.RegisterUDPPorts()
+
+	if f.port != unset {
+		photon.RegisterUDPPorts(uint16(f.port))
+	}
+
+	stream := photon.NewStream(source, photon.StreamOptions{
+		Protocol:  c.protocol,
+		Sequencer: photon.SequencerOptions{GapTimeout: c.gapTimeout},
+		OnError: func(err error) {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		},
+	})
+
+	var printErr error
+
+	stream.Run(func(msg photon.Message) {
+		if printErr == nil && f.matches(msg) {
+			printErr = output(msg)
+		}
+	})
+
+	return printErr
+}
+
+// Opens a pcapng or pcap reader depending on the magic number of the capture.
+func packetSource(r *bufio.Reader) (*gopacket.PacketSource, error) {
+	magic, err := r.Peek(4)
+
+	if err != nil {
+		return nil, fmt.Errorf("Capture is truncated: %s", err.Error())
+	}
+
+	if bytes.Equal(magic, []byte{0x0a, 0x0d, 0x0d, 0x0a}) {
+		reader, err := pcapgo.NewNgReader(r, pcapgo.DefaultNgReaderOptions)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return gopacket.NewPacketSource(reader, reader.LinkType()), nil
+	}
+
+	reader, err := pcapgo.NewReader(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return gopacket.NewPacketSource(reader, reader.LinkType()), nil
+}
+
+func (f filter) matches(msg photon.Message) bool {
+	if f.port != unset {
+		src, dst := msg.Connection.Transport.Endpoints()
+
+		if port(src) != f.port && port(dst) != f.port {
+			return false
+		}
+	}
+
+	if f.peer != unset && int(msg.Connection.PeerID) != f.peer {
+		return false
+	}
+
+	if f.eventCode != unset && (msg.Header.Type != photon.EventDataType || int(msg.Header.EventCode) != f.eventCode) {
+		return false
+	}
+
+	if f.opCode != unset && (msg.Header.Type == photon.EventDataType || int(msg.Header.OperationCode) != f.opCode) {
+		return false
+	}
+
+	return true
+}
+
+func port(endpoint gopacket.Endpoint) int {
+	if len(endpoint.Raw()) != 2 {
+		return unset
+	}
+
+	return int(binary.BigEndian.Uint16(endpoint.Raw()))
+}
+
+// Formats a message as its time, flow, direction, command type, message type,
+// code and paramaters, named by the schema when given.
+func format(msg photon.Message, schema *photon.Schema) string {
+	netSrc, netDst := msg.Connection.Network.Endpoints()
+	portSrc, portDst := msg.Connection.Transport.Endpoints()
+
+	var code string
+
+	switch msg.Header.Type {
+	case photon.EventDataType:
+		code = fmt.Sprintf("event=%d", msg.Header.EventCode)
+	case photon.OperationResponse:
+		code = fmt.Sprintf("op=%d return=%d", msg.Header.OperationCode, msg.Header.OperationResponseCode)
+	default:
+		code = fmt.Sprintf("op=%d", msg.Header.OperationCode)
+	}
+
+	var params interface{} = msg.Paramaters
+
+	if schema != nil {
+		annotation := schema.Annotate(msg.Header, msg.Paramaters)
+
+		if annotation.Name != "" {
+			code += "(" + annotation.Name + ")"
+		}
+
+		named := make([]string, len(annotation.Paramaters))
+
+		for i, param := range annotation.Paramaters {
+			named[i] = fmt.Sprintf("%s:%v", param, param.Value)
+		}
+
+		params = "{" + strings.Join(named, " ") + "}"
+	}
+
+	return fmt.Sprintf("%s %s:%s -> %s:%s peer=%d %s %s %s %s %v",
+		msg.Time.UTC().Format(time.RFC3339Nano),
+		netSrc, portSrc, netDst, portDst,
+		msg.Connection.PeerID,
+		msg.Direction,
+		commandName(msg.CommandType),
+		messageName(msg.Header.Type),
+		code,
+		params)
+}
+
+func commandName(commandType uint8) string {
+	switch commandType {
+	case photon.SendReliableType:
+		return "SendReliable"
+	case photon.SendUnreliableType:
+		return "SendUnreliable"
+	case photon.SendReliableFragmentType:
+		return "SendReliableFragment"
+	default:
+		return fmt.Sprintf("Command(%d)", commandType)
+	}
+}
+
+func messageName(messageType uint8) string {
+	switch messageType {
+	case photon.OperationRequest:
+		return "OperationRequest"
+	case photon.OperationResponse:
+		return "OperationResponse"
+	case photon.EventDataType:
+		return "EventData"
+	default:
+		return fmt.Sprintf("Message(%d)", messageType)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	photon "github.com/hmadison/photon_spectator"
+)
+
+func writeCapture(t *testing.T, events ...uint8) string {
+	path := filepath.Join(t.TempDir(), "capture.pcap")
+	file, err := os.Create(path)
+
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	defer file.Close()
+
+	writer := pcapgo.NewWriter(file)
+	writer.WriteFileHeader(65536, layers.LinkTypeEthernet)
+
+	for i, code := range events {
+		data, _ := photon.EncodeReliableMessage(photon.ReliableMessage{Signature: 0xf3, Type: photon.EventDataType, EventCode: code},
+			photon.ReliableMessageParamaters{"1": int32(i)})
+
+		ethernet := layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02},
+			DstMAC:       net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+			EthernetType: layers.EthernetTypeIPv4,
+		}
+		ip := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 2}, DstIP: net.IP{10, 0, 0, 1}}
+		udp := layers.UDP{SrcPort: 5056, DstPort: 50000}
+		udp.SetNetworkLayerForChecksum(&ip)
+
+		layer := photon.PhotonLayer{PeerID: 7, Commands: []photon.PhotonCommand{{Type: photon.SendReliableType, Data: data}}}
+
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+
+		if err := gopacket.SerializeLayers(buf, opts, &ethernet, &ip, &udp, layer); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		info := gopacket.CaptureInfo{Timestamp: time.Unix(int64(i), 0), CaptureLength: len(buf.Bytes()), Length: len(buf.Bytes())}
+		writer.WritePacket(info, buf.Bytes())
+	}
+
+	return path
+}
+
+var defaults = config{photon.Protocol16, photon.DefaultGapTimeout}
+
+func writeTo(buf *bytes.Buffer) printer {
+	return newPrinter(buf, false, nil, nil)
+}
+
+func TestRun(t *testing.T) {
+	path := writeCapture(t, 1, 2)
+	out := new(bytes.Buffer)
+
+	if err := run(path, defaults, filter{unset, 7, 2, unset}, writeTo(out)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	expected := "1970-01-01T00:00:01Z 10.0.0.2:5056 -> 10.0.0.1:50000 peer=7 ToClient SendReliable EventData event=2 map[1:1]\n"
+
+	if out.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, out.String())
+	}
+}
+
+func TestRun_JSON(t *testing.T) {
+	path := writeCapture(t, 1)
+	out := new(bytes.Buffer)
+
+	if err := run(path, defaults, filter{unset, unset, unset, unset}, photon.NewJSONLWriter(out).Write); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if !strings.Contains(out.String(), `"params":{"1":{"t":"int32","v":0}}`) {
+		t.Errorf("Expected typed paramaters but got %s", out.String())
+	}
+}
+
+func TestRun_Filters(t *testing.T) {
+	path := writeCapture(t, 1, 2)
+
+	filters := []struct {
+		input  filter
+		output int
+	}{
+		{filter{unset, unset, unset, unset}, 2},
+		{filter{5056, unset, unset, unset}, 2},
+		{filter{5055, unset, unset, unset}, 0},
+		{filter{unset, 8, unset, unset}, 0},
+		{filter{unset, unset, 1, unset}, 1},
+		{filter{unset, unset, unset, 1}, 0},
+	}
+
+	for _, f := range filters {
+		out := new(bytes.Buffer)
+
+		if err := run(path, defaults, f.input, writeTo(out)); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		if lines := strings.Count(out.String(), "\n"); lines != f.output {
+			t.Errorf("Expected %d lines for %+v but got %d", f.output, f.input, lines)
+		}
+	}
+}
+
+func TestRun_Schema(t *testing.T) {
+	path := writeCapture(t, 1, 2, 2)
+	out := new(bytes.Buffer)
+	unknown := make(map[string]int)
+
+	schema, _ := photon.ReadSchema(strings.NewReader(`{"events": {"1": {"name": "Join", "paramaters": {"1": {"name": "index"}}}}}`))
+
+	if err := run(path, defaults, filter{unset, unset, 1, unset}, newPrinter(out, false, schema, unknown)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if !strings.HasSuffix(out.String(), "EventData event=1(Join) {index:0}\n") {
+		t.Errorf("Expected named codes and paramaters but got %q", out.String())
+	}
+
+	if err := run(path, defaults, filter{unset, unset, unset, unset}, newPrinter(new(bytes.Buffer), false, schema, unknown)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	report := new(bytes.Buffer)
+	reportUnknown(report, unknown)
+
+	if report.String() != "Unknown event code of 2 seen 2 times\n" {
+		t.Errorf("Unknown report invalid: %q", report.String())
+	}
+}
@@ -0,0 +1,29 @@
+package photon_spectator
+
+import (
+	"github.com/google/gopacket"
+)
+
+// Identifies one direction of traffic between a Photon client and server.
+type ConnectionKey struct {
+	Network   gopacket.Flow
+	Transport gopacket.Flow
+	PeerID    uint16
+}
+
+// Makes the key of the connection a packet was sent on, from its network and
+// transport flows and the PeerID of its Photon header. Layers missing from
+// the packet leave the corresponding flow empty.
+func NewConnectionKey(packet gopacket.Packet, peerID uint16) ConnectionKey {
+	key := ConnectionKey{PeerID: peerID}
+
+	if network := packet.NetworkLayer(); network != nil {
+		key.Network = network.NetworkFlow()
+	}
+
+	if transport := packet.TransportLayer(); transport != nil {
+		key.Transport = transport.TransportFlow()
+	}
+
+	return key
+}
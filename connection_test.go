@@ -0,0 +1,43 @@
+package photon_spectator
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestNewConnectionKey(t *testing.T) {
+	ip := layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.IP{10, 0, 0, 1},
+		DstIP:    net.IP{10, 0, 0, 2},
+	}
+
+	udp := layers.UDP{SrcPort: 50000, DstPort: 5056}
+
+	buf := gopacket.NewSerializeBuffer()
+	gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, &ip, &udp)
+
+	packet := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	key := NewConnectionKey(packet, 1)
+
+	if key.Network.String() != "10.0.0.1->10.0.0.2" {
+		t.Errorf("Network invalid: %s", key.Network)
+	}
+
+	if key.Transport.String() != "50000->5056" {
+		t.Errorf("Transport invalid: %s", key.Transport)
+	}
+
+	if key.PeerID != 1 {
+		t.Errorf("PeerID invalid")
+	}
+
+	if key == NewConnectionKey(packet, 2) {
+		t.Errorf("Keys with different peers should differ")
+	}
+}
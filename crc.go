@@ -0,0 +1,30 @@
+package photon_spectator
+
+import (
+	"errors"
+	"hash/crc32"
+)
+
+const (
+	// Value of CrcEnabled marking a packet which carries a checksum
+	PhotonCrcEnabled = 0xcc
+	// Length of the checksum following the header when CrcEnabled is set
+	PhotonCrcLength = 4
+)
+
+// Returned when a packet's checksum doesn't match and the layer drops invalid
+// checksums.
+var ErrCrcMismatch = errors.New("Photon packet checksum doesn't match")
+
+// Calculates the checksum of a packet as Photon does, with the checksum field
+// itself read as zero. Photon's CRC32 skips the final inversion of the IEEE
+// variant.
+func photonCrc(data []byte) uint32 {
+	var zero [PhotonCrcLength]byte
+
+	crc := crc32.Update(0, crc32.IEEETable, data[:PhotonHeaderLength])
+	crc = crc32.Update(crc, crc32.IEEETable, zero[:])
+	crc = crc32.Update(crc, crc32.IEEETable, data[PhotonHeaderLength+PhotonCrcLength:])
+
+	return ^crc
+}
@@ -0,0 +1,105 @@
+package photon_spectator
+
+import (
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func serializeCrcPacket(t *testing.T) []byte {
+	layer := PhotonLayer{
+		PeerID:     1,
+		CrcEnabled: PhotonCrcEnabled,
+		Commands:   []PhotonCommand{{Type: SendReliableType, Data: []byte{0xca, 0xfe}}},
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+
+	if err := gopacket.SerializeLayers(buf, opts, layer); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	return buf.Bytes()
+}
+
+func TestPhotonLayer_CrcValid(t *testing.T) {
+	data := serializeCrcPacket(t)
+
+	if len(data) != PhotonHeaderLength+PhotonCrcLength+PhotonCommandHeaderLength+2 {
+		t.Errorf("Length invalid")
+	}
+
+	packet := gopacket.NewPacket(data, PhotonLayerType, gopacket.Default)
+	layer, ok := packet.Layer(PhotonLayerType).(PhotonLayer)
+
+	if !ok {
+		t.Fatalf("Photon layer should be present")
+	}
+
+	if !layer.CrcValid {
+		t.Errorf("CrcValid invalid")
+	}
+
+	if layer.Crc != photonCrc(data) {
+		t.Errorf("Crc invalid")
+	}
+
+	if len(layer.Commands) != 1 || layer.Commands[0].Type != SendReliableType {
+		t.Errorf("Commands invalid")
+	}
+}
+
+func TestPhotonLayer_CrcInvalid(t *testing.T) {
+	data := serializeCrcPacket(t)
+	data[len(data)-1] ^= 0xff
+
+	packet := gopacket.NewPacket(data, PhotonLayerType, gopacket.Default)
+	layer, ok := packet.Layer(PhotonLayerType).(PhotonLayer)
+
+	if !ok {
+		t.Fatalf("Photon layer should be present")
+	}
+
+	if layer.CrcValid {
+		t.Errorf("CrcValid invalid")
+	}
+}
+
+func TestPhotonLayer_CrcInvalidDropped(t *testing.T) {
+	data := serializeCrcPacket(t)
+	data[len(data)-1] ^= 0xff
+
+	layer := PhotonLayer{DropInvalidCrc: true}
+
+	if err := layer.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != ErrCrcMismatch {
+		t.Errorf("Expected %v but got %v", ErrCrcMismatch, err)
+	}
+
+	layer = PhotonLayer{}
+
+	if err := layer.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil || layer.CrcValid {
+		t.Errorf("Expected the checksum to be flagged but got %v", err)
+	}
+
+	packet := gopacket.NewPacket(data, PhotonLayerTypeDropInvalidCrc, gopacket.Default)
+
+	if packet.Layer(PhotonLayerType) != nil {
+		t.Errorf("Photon layer should be absent")
+	}
+
+	failure, ok := packet.ErrorLayer().(*gopacket.DecodeFailure)
+
+	if !ok || failure.Error() != ErrCrcMismatch {
+		t.Errorf("Expected %v", ErrCrcMismatch)
+	}
+}
+
+func TestPhotonCrc(t *testing.T) {
+	// Photon's CRC32 matches the IEEE checksum without the final inversion
+	data := make([]byte, PhotonHeaderLength+PhotonCrcLength)
+
+	if photonCrc(data) != 0x1344b4aa {
+		t.Errorf("Expected %#x but got %#x", 0x1344b4aa, photonCrc(data))
+	}
+}
@@ -0,0 +1,54 @@
+package photon_spectator
+
+import (
+	"sync"
+)
+
+const (
+	CustomType = 99
+)
+
+// Converts the serialized contents of a custom type into a value.
+type CustomTypeDecoder func(data []byte) (interface{}, error)
+
+// The raw contents of a custom type which has no registered decoder.
+type CustomTypeValue struct {
+	Code uint8
+	Data []byte
+}
+
+var customTypes = struct {
+	sync.RWMutex
+	decoders map[uint8]CustomTypeDecoder
+}{decoders: make(map[uint8]CustomTypeDecoder)}
+
+// Registers a decoder for the given custom type code, replacing any decoder
+// previously registered for it.
+func RegisterCustomType(code uint8, decoder CustomTypeDecoder) {
+	customTypes.Lock()
+	defer customTypes.Unlock()
+
+	customTypes.decoders[code] = decoder
+}
+
+// Removes the decoder registered for the given custom type code, if any.
+func UnregisterCustomType(code uint8) {
+	customTypes.Lock()
+	defer customTypes.Unlock()
+
+	delete(customTypes.decoders, code)
+}
+
+// Decodes the contents of a custom type with its registered decoder. Codes
+// without a decoder are returned as a CustomTypeValue.
+func decodeCustomType(code uint8, data []byte) (interface{}, error) {
+	customTypes.RLock()
+	decoder, ok := customTypes.decoders[code]
+	customTypes.RUnlock()
+
+	if !ok {
+		return CustomTypeValue{Code: code, Data: data}, nil
+	}
+
+	return decoder(data)
+}
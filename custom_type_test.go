@@ -0,0 +1,110 @@
+package photon_spectator
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type vector2 struct {
+	X int8
+	Y int8
+}
+
+func decodeVector2(data []byte) (interface{}, error) {
+	if len(data) != 2 {
+		return nil, fmt.Errorf("Invalid vector length of %d", len(data))
+	}
+
+	return vector2{X: int8(data[0]), Y: int8(data[1])}, nil
+}
+
+func TestDecodeReliableMessage_CustomType(t *testing.T) {
+	RegisterCustomType(0x56, decodeVector2)
+	defer UnregisterCustomType(0x56)
+
+	var msg ReliableMessage
+	msg.ParamaterCount = 2
+	msg.Data = []byte{
+		0x00, CustomType, 0x56, 0x00, 0x02, 0x01, 0xff,
+		0x01, SliceType, 0x00, 0x02, CustomType, 0x56, 0x00, 0x02, 0x01, 0x02, 0x00, 0x02, 0x03, 0x04,
+	}
+
+	actual, err := DecodeReliableMessage(msg)
+
+	if err != nil {
+		t.Errorf("%s", err.Error())
+	}
+
+	expected := ReliableMessageParamaters{
+		"0": vector2{X: 1, Y: -1},
+		"1": []interface{}{vector2{X: 1, Y: 2}, vector2{X: 3, Y: 4}},
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected `%#v` but got `%#v`", expected, actual)
+	}
+}
+
+func TestDecodeReliableMessage_CustomTypeUnknown(t *testing.T) {
+	var msg ReliableMessage
+	msg.ParamaterCount = 1
+	msg.Data = []byte{0x00, CustomType, 0x57, 0x00, 0x01, 0xca}
+
+	actual, err := DecodeReliableMessage(msg)
+
+	if err != nil {
+		t.Errorf("%s", err.Error())
+	}
+
+	expected := ReliableMessageParamaters{"0": CustomTypeValue{Code: 0x57, Data: []byte{0xca}}}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected `%#v` but got `%#v`", expected, actual)
+	}
+}
+
+func TestDecodeReliableMessage_CustomTypeError(t *testing.T) {
+	RegisterCustomType(0x56, decodeVector2)
+	defer UnregisterCustomType(0x56)
+
+	var msg ReliableMessage
+	msg.ParamaterCount = 1
+	msg.Data = []byte{0x00, CustomType, 0x56, 0x00, 0x01, 0x01}
+
+	_, err := DecodeReliableMessage(msg)
+
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestDecodeReliableMessage_Protocol18CustomType(t *testing.T) {
+	RegisterCustomType(0x56, decodeVector2)
+	defer UnregisterCustomType(0x56)
+
+	var msg ReliableMessage
+	msg.Protocol = Protocol18
+	msg.ParamaterCount = 3
+	msg.Data = []byte{
+		0x00, Protocol18CustomType, 0x56, 0x02, 0x01, 0xff,
+		0x01, Protocol18CustomSlimType + 0x56, 0x02, 0x01, 0x02,
+		0x02, Protocol18SliceCustomType, 0x01, 0x57, 0x01, 0xca,
+	}
+
+	actual, err := DecodeReliableMessage(msg)
+
+	if err != nil {
+		t.Errorf("%s", err.Error())
+	}
+
+	expected := ReliableMessageParamaters{
+		"0": vector2{X: 1, Y: -1},
+		"1": vector2{X: 1, Y: 2},
+		"2": []interface{}{CustomTypeValue{Code: 0x57, Data: []byte{0xca}}},
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected `%#v` but got `%#v`", expected, actual)
+	}
+}
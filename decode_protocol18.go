@@ -0,0 +1,662 @@
+package photon_spectator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	Protocol18UnknownType              = 0
+	Protocol18BooleanType              = 2
+	Protocol18ByteType                 = 3
+	Protocol18Int16Type                = 4
+	Protocol18Float32Type              = 5
+	Protocol18DoubleType               = 6
+	Protocol18StringType               = 7
+	Protocol18NilType                  = 8
+	Protocol18CompressedInt32Type      = 9
+	Protocol18CompressedInt64Type      = 10
+	Protocol18Int32PositiveByteType    = 11
+	Protocol18Int32NegativeByteType    = 12
+	Protocol18Int32PositiveShortType   = 13
+	Protocol18Int32NegativeShortType   = 14
+	Protocol18Int64PositiveByteType    = 15
+	Protocol18Int64NegativeByteType    = 16
+	Protocol18Int64PositiveShortType   = 17
+	Protocol18Int64NegativeShortType   = 18
+	Protocol18CustomType               = 19
+	Protocol18DictionaryType           = 20
+	Protocol18HashtableType            = 21
+	Protocol18SliceObjectType          = 23
+	Protocol18OperationRequestType     = 24
+	Protocol18OperationResponseType    = 25
+	Protocol18EventDataType            = 26
+	Protocol18BooleanFalseType         = 27
+	Protocol18BooleanTrueType          = 28
+	Protocol18Int16ZeroType            = 29
+	Protocol18Int32ZeroType            = 30
+	Protocol18Int64ZeroType            = 31
+	Protocol18Float32ZeroType          = 32
+	Protocol18DoubleZeroType           = 33
+	Protocol18ByteZeroType             = 34
+	Protocol18SliceType                = 64
+	Protocol18SliceBooleanType         = 66
+	Protocol18SliceByteType            = 67
+	Protocol18SliceInt16Type           = 68
+	Protocol18SliceFloat32Type         = 69
+	Protocol18SliceDoubleType          = 70
+	Protocol18SliceStringType          = 71
+	Protocol18SliceCompressedInt32Type = 73
+	Protocol18SliceCompressedInt64Type = 74
+	Protocol18SliceCustomType          = 83
+	Protocol18SliceDictionaryType      = 84
+	Protocol18SliceHashtableType       = 85
+	Protocol18CustomSlimType           = 128
+)
+
+// Reads the Protocol18 message header following the signature and type bytes.
+// Protocol18 counts paramaters with a single byte and writes the response
+// debug message as a full value rather than a single byte.
+func readProtocol18MessageHeader(buf *bytes.Buffer, msg *ReliableMessage) error {
+	var count uint8
+
+	switch msg.Type {
+	case OperationRequest:
+		binary.Read(buf, binary.LittleEndian, &msg.OperationCode)
+	case EventDataType:
+		binary.Read(buf, binary.LittleEndian, &msg.EventCode)
+	case OperationResponse:
+		binary.Read(buf, binary.LittleEndian, &msg.OperationCode)
+		binary.Read(buf, binary.LittleEndian, &msg.OperationResponseCode)
+		binary.Read(buf, binary.LittleEndian, &msg.OperationDebugByte)
+
+		debugMessage, err := decodeProtocol18Type(buf, msg.OperationDebugByte)
+
+		if err != nil {
+			return err
+		}
+
+		msg.OperationDebugMessage = debugMessage
+	}
+
+	binary.Read(buf, binary.LittleEndian, &count)
+	msg.ParamaterCount = int16(count)
+
+	return nil
+}
+
+func decodeProtocol18Paramaters(buf *bytes.Buffer, count int) (ReliableMessageParamaters, error) {
+	params, err := decodeParamaterList(buf, count, decodeProtocol18Type)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return params.Map(), nil
+}
+
+// Decodes a single Protocol18 value of the given type from the buffer.
+func decodeProtocol18Type(buf *bytes.Buffer, paramType uint8) (interface{}, error) {
+	if paramType >= Protocol18CustomSlimType {
+		return decodeProtocol18CustomType(buf, paramType-Protocol18CustomSlimType)
+	}
+
+	switch paramType {
+	case Protocol18UnknownType, Protocol18NilType:
+		return nil, nil
+	case Protocol18BooleanType:
+		return decodeBooleanType(buf)
+	case Protocol18ByteType:
+		return decodeByteType(buf), nil
+	case Protocol18Int16Type:
+		return decodeProtocol18Int16Type(buf), nil
+	case Protocol18Float32Type:
+		return decodeProtocol18Float32Type(buf), nil
+	case Protocol18DoubleType:
+		return decodeProtocol18DoubleType(buf), nil
+	case Protocol18StringType:
+		return decodeProtocol18StringType(buf)
+	case Protocol18CompressedInt32Type:
+		return decodeProtocol18CompressedInt32Type(buf), nil
+	case Protocol18CompressedInt64Type:
+		return decodeProtocol18CompressedInt64Type(buf), nil
+	case Protocol18Int32PositiveByteType:
+		return int32(decodeByteType(buf)), nil
+	case Protocol18Int32NegativeByteType:
+		return -int32(decodeByteType(buf)), nil
+	case Protocol18Int32PositiveShortType:
+		return int32(decodeProtocol18Uint16Type(buf)), nil
+	case Protocol18Int32NegativeShortType:
+		return -int32(decodeProtocol18Uint16Type(buf)), nil
+	case Protocol18Int64PositiveByteType:
+		return int64(decodeByteType(buf)), nil
+	case Protocol18Int64NegativeByteType:
+		return -int64(decodeByteType(buf)), nil
+	case Protocol18Int64PositiveShortType:
+		return int64(decodeProtocol18Uint16Type(buf)), nil
+	case Protocol18Int64NegativeShortType:
+		return -int64(decodeProtocol18Uint16Type(buf)), nil
+	case Protocol18CustomType:
+		return decodeProtocol18CustomType(buf, decodeByteType(buf))
+	case Protocol18DictionaryType:
+		keyType, valueType, err := decodeProtocol18DictionaryHeader(buf)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return decodeProtocol18DictionaryEntries(buf, keyType, valueType)
+	case Protocol18HashtableType:
+		return decodeProtocol18HashtableType(buf)
+	case Protocol18SliceObjectType, Protocol18SliceType:
+		return decodeProtocol18SliceObjectType(buf)
+	case Protocol18OperationRequestType:
+		return decodeProtocol18OperationRequestValue(buf)
+	case Protocol18OperationResponseType:
+		return decodeProtocol18OperationResponseValue(buf)
+	case Protocol18EventDataType:
+		return decodeProtocol18EventDataValue(buf)
+	case Protocol18BooleanFalseType:
+		return false, nil
+	case Protocol18BooleanTrueType:
+		return true, nil
+	case Protocol18Int16ZeroType:
+		return int16(0), nil
+	case Protocol18Int32ZeroType:
+		return int32(0), nil
+	case Protocol18Int64ZeroType:
+		return int64(0), nil
+	case Protocol18Float32ZeroType:
+		return float32(0), nil
+	case Protocol18DoubleZeroType:
+		return float64(0), nil
+	case Protocol18ByteZeroType:
+		return uint8(0), nil
+	case Protocol18SliceBooleanType:
+		return decodeProtocol18SliceBooleanType(buf)
+	case Protocol18SliceByteType:
+		length, err := decodeProtocol18Length(buf, 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		array := make([]uint8, length)
+		buf.Read(array)
+		return array, nil
+	case Protocol18SliceInt16Type:
+		length, err := decodeProtocol18Length(buf, 2)
+
+		if err != nil {
+			return nil, err
+		}
+
+		array := make([]int16, length)
+
+		for j := 0; j < int(length); j++ {
+			array[j] = decodeProtocol18Int16Type(buf)
+		}
+
+		return array, nil
+	case Protocol18SliceFloat32Type:
+		length, err := decodeProtocol18Length(buf, 4)
+
+		if err != nil {
+			return nil, err
+		}
+
+		array := make([]float32, length)
+
+		for j := 0; j < int(length); j++ {
+			array[j] = decodeProtocol18Float32Type(buf)
+		}
+
+		return array, nil
+	case Protocol18SliceDoubleType:
+		length, err := decodeProtocol18Length(buf, 8)
+
+		if err != nil {
+			return nil, err
+		}
+
+		array := make([]float64, length)
+
+		for j := 0; j < int(length); j++ {
+			array[j] = decodeProtocol18DoubleType(buf)
+		}
+
+		return array, nil
+	case Protocol18SliceStringType:
+		length, err := decodeProtocol18Length(buf, 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		array := make([]string, length)
+
+		for j := 0; j < int(length); j++ {
+			str, err := decodeProtocol18StringType(buf)
+
+			if err != nil {
+				return nil, err
+			}
+
+			array[j] = str
+		}
+
+		return array, nil
+	case Protocol18SliceCompressedInt32Type:
+		length, err := decodeProtocol18Length(buf, 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		array := make([]int32, length)
+
+		for j := 0; j < int(length); j++ {
+			array[j] = decodeProtocol18CompressedInt32Type(buf)
+		}
+
+		return array, nil
+	case Protocol18SliceCompressedInt64Type:
+		length, err := decodeProtocol18Length(buf, 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		array := make([]int64, length)
+
+		for j := 0; j < int(length); j++ {
+			array[j] = decodeProtocol18CompressedInt64Type(buf)
+		}
+
+		return array, nil
+	case Protocol18SliceDictionaryType:
+		keyType, valueType, err := decodeProtocol18DictionaryHeader(buf)
+
+		if err != nil {
+			return nil, err
+		}
+
+		length, err := decodeProtocol18Length(buf, 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		array := make([]map[interface{}]interface{}, length)
+
+		for j := 0; j < int(length); j++ {
+			dictionary, err := decodeProtocol18DictionaryEntries(buf, keyType, valueType)
+
+			if err != nil {
+				return nil, err
+			}
+
+			array[j] = dictionary
+		}
+
+		return array, nil
+	case Protocol18SliceCustomType:
+		length, err := decodeProtocol18Length(buf, 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		code := decodeByteType(buf)
+		array := make([]interface{}, length)
+
+		for j := 0; j < int(length); j++ {
+			value, err := decodeProtocol18CustomType(buf, code)
+
+			if err != nil {
+				return nil, err
+			}
+
+			array[j] = value
+		}
+
+		return array, nil
+	case Protocol18SliceHashtableType:
+		length, err := decodeProtocol18Length(buf, 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		array := make([]map[interface{}]interface{}, length)
+
+		for j := 0; j < int(length); j++ {
+			hashtable, err := decodeProtocol18HashtableType(buf)
+
+			if err != nil {
+				return nil, err
+			}
+
+			array[j] = hashtable
+		}
+
+		return array, nil
+	default:
+		return nil, fmt.Errorf("Invalid type of %d", paramType)
+	}
+}
+
+func decodeProtocol18Int16Type(buf *bytes.Buffer) (temp int16) {
+	binary.Read(buf, binary.LittleEndian, &temp)
+	return
+}
+
+func decodeProtocol18Uint16Type(buf *bytes.Buffer) (temp uint16) {
+	binary.Read(buf, binary.LittleEndian, &temp)
+	return
+}
+
+func decodeProtocol18Float32Type(buf *bytes.Buffer) (temp float32) {
+	binary.Read(buf, binary.LittleEndian, &temp)
+	return
+}
+
+func decodeProtocol18DoubleType(buf *bytes.Buffer) (temp float64) {
+	binary.Read(buf, binary.LittleEndian, &temp)
+	return
+}
+
+func decodeProtocol18StringType(buf *bytes.Buffer) (string, error) {
+	length, err := decodeProtocol18Length(buf, 1)
+
+	if err != nil {
+		return "", err
+	}
+
+	strBytes := make([]byte, length)
+	buf.Read(strBytes)
+
+	return string(strBytes[:]), nil
+}
+
+func decodeProtocol18CustomType(buf *bytes.Buffer, code uint8) (interface{}, error) {
+	length, err := decodeProtocol18Length(buf, 1)
+
+	if err != nil {
+		return nil, fmt.Errorf("Custom Type %d Error: %s", code, err.Error())
+	}
+
+	data := make([]byte, length)
+	buf.Read(data)
+
+	value, err := decodeCustomType(code, data)
+
+	if err != nil {
+		return nil, fmt.Errorf("Custom Type %d Error: %s", code, err.Error())
+	}
+
+	return value, nil
+}
+
+// Reads the length of a slice, map or string whose elements are serialized in
+// at least the given number of bytes. Errors if the bytes remaining can't hold
+// that many elements.
+func decodeProtocol18Length(buf *bytes.Buffer, size int) (int, error) {
+	length := int(decodeProtocol18CompressedUint32(buf))
+
+	if err := checkLength(buf, length, size); err != nil {
+		return 0, err
+	}
+
+	return length, nil
+}
+
+// Reads an unsigned varint made of 7 bit groups, least significant first.
+func decodeProtocol18CompressedUint64(buf *bytes.Buffer) uint64 {
+	var value uint64
+
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := buf.ReadByte()
+
+		if err != nil {
+			break
+		}
+
+		value |= uint64(b&0x7f) << shift
+
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	return value
+}
+
+func decodeProtocol18CompressedUint32(buf *bytes.Buffer) uint32 {
+	return uint32(decodeProtocol18CompressedUint64(buf))
+}
+
+// Reads a zigzag encoded varint.
+func decodeProtocol18CompressedInt32Type(buf *bytes.Buffer) int32 {
+	value := decodeProtocol18CompressedUint32(buf)
+	return int32(value>>1) ^ -int32(value&1)
+}
+
+// Reads a zigzag encoded varint.
+func decodeProtocol18CompressedInt64Type(buf *bytes.Buffer) int64 {
+	value := decodeProtocol18CompressedUint64(buf)
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+// Booleans in a slice are packed eight to a byte, lowest bit first.
+func decodeProtocol18SliceBooleanType(buf *bytes.Buffer) ([]bool, error) {
+	length := int(decodeProtocol18CompressedUint32(buf))
+
+	if length < 0 || length > buf.Len()*8 {
+		return nil, fmt.Errorf("Length of %d exceeds the %d bytes remaining", length, buf.Len())
+	}
+
+	array := make([]bool, length)
+
+	var packed uint8
+
+	for j := 0; j < int(length); j++ {
+		if j%8 == 0 {
+			packed = decodeByteType(buf)
+		}
+
+		array[j] = packed&(1<<uint(j%8)) != 0
+	}
+
+	return array, nil
+}
+
+func decodeProtocol18HashtableType(buf *bytes.Buffer) (map[interface{}]interface{}, error) {
+	size, err := decodeProtocol18Length(buf, 2)
+
+	if err != nil {
+		return nil, fmt.Errorf("Hashtable Error: %s", err.Error())
+	}
+
+	hashtable := make(map[interface{}]interface{}, size)
+
+	for j := 0; j < int(size); j++ {
+		var keyType uint8
+		var valueType uint8
+
+		binary.Read(buf, binary.LittleEndian, &keyType)
+		key, err := decodeProtocol18Type(buf, keyType)
+
+		if err != nil {
+			return nil, fmt.Errorf("Hashtable Error: %s", err.Error())
+		}
+
+		binary.Read(buf, binary.LittleEndian, &valueType)
+		value, err := decodeProtocol18Type(buf, valueType)
+
+		if err != nil {
+			return nil, fmt.Errorf("Hashtable Error: %s", err.Error())
+		}
+
+		if err := putMapEntry(hashtable, key, value); err != nil {
+			return nil, fmt.Errorf("Hashtable Error: %s", err.Error())
+		}
+	}
+
+	return hashtable, nil
+}
+
+// Reads the key and value types of a dictionary. Nested dictionary and slice
+// value types carry further type bytes describing their contents, which are
+// consumed here as each nested value repeats them when it is read. Values of
+// slices of slices carry their own type, as Photon writes them.
+func decodeProtocol18DictionaryHeader(buf *bytes.Buffer) (keyType uint8, valueType uint8, err error) {
+	if keyType, err = buf.ReadByte(); err != nil {
+		return 0, 0, fmt.Errorf("Dictionary Error: header is truncated")
+	}
+
+	if valueType, err = buf.ReadByte(); err != nil {
+		return 0, 0, fmt.Errorf("Dictionary Error: header is truncated")
+	}
+
+	switch valueType {
+	case Protocol18DictionaryType:
+		if _, _, err := decodeProtocol18DictionaryHeader(buf); err != nil {
+			return 0, 0, err
+		}
+	case Protocol18SliceType:
+		for nestedType := valueType; nestedType == Protocol18SliceType; {
+			if nestedType, err = buf.ReadByte(); err != nil {
+				return 0, 0, fmt.Errorf("Dictionary Error: header is truncated")
+			}
+		}
+
+		valueType = Protocol18UnknownType
+	}
+
+	return keyType, valueType, nil
+}
+
+func decodeProtocol18DictionaryEntries(buf *bytes.Buffer, keyType uint8, valueType uint8) (map[interface{}]interface{}, error) {
+	size, err := decodeProtocol18Length(buf, 1)
+
+	if err != nil {
+		return nil, fmt.Errorf("Dictionary Error: %s", err.Error())
+	}
+
+	dictionary := make(map[interface{}]interface{}, size)
+
+	for j := 0; j < int(size); j++ {
+		entryKeyType := keyType
+		entryValueType := valueType
+
+		if entryKeyType == Protocol18UnknownType {
+			binary.Read(buf, binary.LittleEndian, &entryKeyType)
+		}
+
+		key, err := decodeProtocol18Type(buf, entryKeyType)
+
+		if err != nil {
+			return nil, fmt.Errorf("Dictionary Error: %s", err.Error())
+		}
+
+		if entryValueType == Protocol18UnknownType {
+			binary.Read(buf, binary.LittleEndian, &entryValueType)
+		}
+
+		value, err := decodeProtocol18Type(buf, entryValueType)
+
+		if err != nil {
+			return nil, fmt.Errorf("Dictionary Error: %s", err.Error())
+		}
+
+		if err := putMapEntry(dictionary, key, value); err != nil {
+			return nil, fmt.Errorf("Dictionary Error: %s", err.Error())
+		}
+	}
+
+	return dictionary, nil
+}
+
+// Decodes an object slice, where every element is prefixed by its own type.
+func decodeProtocol18SliceObjectType(buf *bytes.Buffer) ([]interface{}, error) {
+	length, err := decodeProtocol18Length(buf, 1)
+
+	if err != nil {
+		return nil, fmt.Errorf("Object Slice Error: %s", err.Error())
+	}
+
+	array := make([]interface{}, length)
+
+	for j := 0; j < int(length); j++ {
+		var elementType uint8
+
+		binary.Read(buf, binary.LittleEndian, &elementType)
+		element, err := decodeProtocol18Type(buf, elementType)
+
+		if err != nil {
+			return nil, fmt.Errorf("Object Slice Error: %s", err.Error())
+		}
+
+		array[j] = element
+	}
+
+	return array, nil
+}
+
+func decodeProtocol18EventDataValue(buf *bytes.Buffer) (event EventDataValue, err error) {
+	var count uint8
+
+	binary.Read(buf, binary.LittleEndian, &event.Code)
+	binary.Read(buf, binary.LittleEndian, &count)
+
+	event.Paramaters, err = decodeProtocol18Paramaters(buf, int(count))
+
+	if err != nil {
+		err = fmt.Errorf("EventData Error: %s", err.Error())
+	}
+
+	return
+}
+
+func decodeProtocol18OperationRequestValue(buf *bytes.Buffer) (request OperationRequestValue, err error) {
+	var count uint8
+
+	binary.Read(buf, binary.LittleEndian, &request.Code)
+	binary.Read(buf, binary.LittleEndian, &count)
+
+	request.Paramaters, err = decodeProtocol18Paramaters(buf, int(count))
+
+	if err != nil {
+		err = fmt.Errorf("OperationRequest Error: %s", err.Error())
+	}
+
+	return
+}
+
+func decodeProtocol18OperationResponseValue(buf *bytes.Buffer) (response OperationResponseValue, err error) {
+	var debugType uint8
+	var count uint8
+
+	binary.Read(buf, binary.LittleEndian, &response.Code)
+	binary.Read(buf, binary.LittleEndian, &response.ReturnCode)
+	binary.Read(buf, binary.LittleEndian, &debugType)
+
+	response.DebugMessage, err = decodeProtocol18Type(buf, debugType)
+
+	if err != nil {
+		err = fmt.Errorf("OperationResponse Error: %s", err.Error())
+		return
+	}
+
+	binary.Read(buf, binary.LittleEndian, &count)
+
+	response.Paramaters, err = decodeProtocol18Paramaters(buf, int(count))
+
+	if err != nil {
+		err = fmt.Errorf("OperationResponse Error: %s", err.Error())
+	}
+
+	return
+}
@@ -0,0 +1,276 @@
+package photon_spectator
+
+import (
+	"reflect"
+	"testing"
+)
+
+var protocol18Responses = []struct {
+	input  []byte
+	output ReliableMessageParamaters
+}{
+	{
+		[]byte{0x00, Protocol18BooleanType, 0x01},
+		ReliableMessageParamaters{"0": true},
+	},
+	{
+		[]byte{0x00, Protocol18ByteType, 0xff},
+		ReliableMessageParamaters{"0": uint8(255)},
+	},
+	{
+		[]byte{0x00, Protocol18Int16Type, 0x80, 0x00},
+		ReliableMessageParamaters{"0": int16(128)},
+	},
+	{
+		[]byte{0x00, Protocol18Float32Type, 0xc5, 0x20, 0x00, 0x43},
+		ReliableMessageParamaters{"0": float32(128.128)},
+	},
+	{
+		[]byte{0x00, Protocol18DoubleType, 0x6a, 0xbc, 0x74, 0x93, 0x18, 0x04, 0x60, 0x40},
+		ReliableMessageParamaters{"0": float64(128.128)},
+	},
+	{
+		[]byte{0x00, Protocol18StringType, 0x03, 0x61, 0x62, 0x63},
+		ReliableMessageParamaters{"0": "abc"},
+	},
+	{
+		[]byte{0x00, Protocol18NilType},
+		ReliableMessageParamaters{},
+	},
+	{
+		[]byte{0x00, Protocol18CompressedInt32Type, 0x80, 0x02},
+		ReliableMessageParamaters{"0": int32(128)},
+	},
+	{
+		[]byte{0x00, Protocol18CompressedInt32Type, 0x03},
+		ReliableMessageParamaters{"0": int32(-2)},
+	},
+	{
+		[]byte{0x00, Protocol18CompressedInt64Type, 0xff, 0x01},
+		ReliableMessageParamaters{"0": int64(-128)},
+	},
+	{
+		[]byte{0x00, Protocol18Int32PositiveByteType, 0x80},
+		ReliableMessageParamaters{"0": int32(128)},
+	},
+	{
+		[]byte{0x00, Protocol18Int32NegativeByteType, 0x80},
+		ReliableMessageParamaters{"0": int32(-128)},
+	},
+	{
+		[]byte{0x00, Protocol18Int32PositiveShortType, 0x00, 0x01},
+		ReliableMessageParamaters{"0": int32(256)},
+	},
+	{
+		[]byte{0x00, Protocol18Int32NegativeShortType, 0x00, 0x01},
+		ReliableMessageParamaters{"0": int32(-256)},
+	},
+	{
+		[]byte{0x00, Protocol18Int64PositiveByteType, 0x80},
+		ReliableMessageParamaters{"0": int64(128)},
+	},
+	{
+		[]byte{0x00, Protocol18Int64NegativeShortType, 0x00, 0x01},
+		ReliableMessageParamaters{"0": int64(-256)},
+	},
+	{
+		[]byte{0x00, Protocol18BooleanFalseType, 0x01, Protocol18BooleanTrueType},
+		ReliableMessageParamaters{"0": false, "1": true},
+	},
+	{
+		[]byte{0x00, Protocol18Int16ZeroType, 0x01, Protocol18Int32ZeroType, 0x02, Protocol18Int64ZeroType},
+		ReliableMessageParamaters{"0": int16(0), "1": int32(0), "2": int64(0)},
+	},
+	{
+		[]byte{0x00, Protocol18Float32ZeroType, 0x01, Protocol18DoubleZeroType, 0x02, Protocol18ByteZeroType},
+		ReliableMessageParamaters{"0": float32(0), "1": float64(0), "2": uint8(0)},
+	},
+	{
+		[]byte{0x00, Protocol18HashtableType, 0x01, Protocol18ByteType, 0x01, Protocol18StringType, 0x01, 0x61},
+		ReliableMessageParamaters{"0": map[interface{}]interface{}{uint8(1): "a"}},
+	},
+	{
+		[]byte{0x00, Protocol18DictionaryType, Protocol18StringType, Protocol18UnknownType, 0x01, 0x01, 0x61, Protocol18BooleanTrueType},
+		ReliableMessageParamaters{"0": map[interface{}]interface{}{"a": true}},
+	},
+	{
+		[]byte{
+			0x00, Protocol18DictionaryType, Protocol18ByteType, Protocol18DictionaryType, Protocol18ByteType, Protocol18ByteType, 0x01,
+			0x01, Protocol18ByteType, Protocol18ByteType, 0x01, 0x02, 0x03,
+		},
+		ReliableMessageParamaters{"0": map[interface{}]interface{}{uint8(1): map[interface{}]interface{}{uint8(2): uint8(3)}}},
+	},
+	{
+		// Dictionary<byte, short[][]> as Photon serializes it, declaring the
+		// nested element type up front and prefixing each value with its type
+		[]byte{
+			0x00, Protocol18DictionaryType, Protocol18ByteType, Protocol18SliceType, Protocol18SliceInt16Type, 0x01,
+			0x01, Protocol18SliceType, 0x01, Protocol18SliceInt16Type, 0x01, 0x05, 0x00,
+		},
+		ReliableMessageParamaters{"0": map[interface{}]interface{}{uint8(1): []interface{}{[]int16{5}}}},
+	},
+	{
+		[]byte{0x00, Protocol18SliceObjectType, 0x02, Protocol18ByteZeroType, Protocol18StringType, 0x01, 0x61},
+		ReliableMessageParamaters{"0": []interface{}{uint8(0), "a"}},
+	},
+	{
+		[]byte{0x00, Protocol18SliceBooleanType, 0x09, 0x81, 0x01},
+		ReliableMessageParamaters{"0": []bool{true, false, false, false, false, false, false, true, true}},
+	},
+	{
+		[]byte{0x00, Protocol18SliceByteType, 0x02, 0xca, 0xfe},
+		ReliableMessageParamaters{"0": []uint8{0xca, 0xfe}},
+	},
+	{
+		[]byte{0x00, Protocol18SliceInt16Type, 0x01, 0x80, 0x00},
+		ReliableMessageParamaters{"0": []int16{128}},
+	},
+	{
+		[]byte{0x00, Protocol18SliceFloat32Type, 0x01, 0xc5, 0x20, 0x00, 0x43},
+		ReliableMessageParamaters{"0": []float32{128.128}},
+	},
+	{
+		[]byte{0x00, Protocol18SliceDoubleType, 0x01, 0x6a, 0xbc, 0x74, 0x93, 0x18, 0x04, 0x60, 0x40},
+		ReliableMessageParamaters{"0": []float64{128.128}},
+	},
+	{
+		[]byte{0x00, Protocol18SliceStringType, 0x02, 0x01, 0x61, 0x00},
+		ReliableMessageParamaters{"0": []string{"a", ""}},
+	},
+	{
+		[]byte{0x00, Protocol18SliceCompressedInt32Type, 0x02, 0x02, 0x01},
+		ReliableMessageParamaters{"0": []int32{1, -1}},
+	},
+	{
+		[]byte{0x00, Protocol18SliceCompressedInt64Type, 0x02, 0x02, 0x01},
+		ReliableMessageParamaters{"0": []int64{1, -1}},
+	},
+	{
+		[]byte{0x00, Protocol18SliceDictionaryType, Protocol18ByteType, Protocol18ByteType, 0x02, 0x01, 0x01, 0x02, 0x00},
+		ReliableMessageParamaters{"0": []map[interface{}]interface{}{{uint8(1): uint8(2)}, {}}},
+	},
+	{
+		[]byte{0x00, Protocol18SliceHashtableType, 0x01, 0x01, Protocol18ByteType, 0x01, Protocol18ByteType, 0x02},
+		ReliableMessageParamaters{"0": []map[interface{}]interface{}{{uint8(1): uint8(2)}}},
+	},
+	{
+		[]byte{0x00, Protocol18EventDataType, 0x01, 0x01, 0x02, Protocol18ByteType, 0x03},
+		ReliableMessageParamaters{"0": EventDataValue{Code: 1, Paramaters: ReliableMessageParamaters{"2": uint8(3)}}},
+	},
+	{
+		[]byte{0x00, Protocol18OperationRequestType, 0x01, 0x01, 0x02, Protocol18ByteType, 0x03},
+		ReliableMessageParamaters{"0": OperationRequestValue{Code: 1, Paramaters: ReliableMessageParamaters{"2": uint8(3)}}},
+	},
+	{
+		[]byte{0x00, Protocol18OperationResponseType, 0x01, 0xff, 0xff, Protocol18StringType, 0x01, 0x61, 0x00},
+		ReliableMessageParamaters{"0": OperationResponseValue{Code: 1, ReturnCode: -1, DebugMessage: "a", Paramaters: ReliableMessageParamaters{}}},
+	},
+}
+
+func TestDecodeReliableMessage_Protocol18(t *testing.T) {
+	for _, r := range protocol18Responses {
+		var msg ReliableMessage
+		msg.Protocol = Protocol18
+		msg.ParamaterCount = int16(len(r.output))
+		msg.Data = r.input
+
+		if msg.ParamaterCount == 0 {
+			msg.ParamaterCount = 1
+		}
+
+		actual, err := DecodeReliableMessage(msg)
+
+		if err != nil {
+			t.Errorf("%s", err.Error())
+		}
+
+		if !reflect.DeepEqual(r.output, actual) {
+			t.Errorf("Expected `%#v` but got `%#v`", r.output, actual)
+		}
+	}
+}
+
+func TestDecodeReliableMessage_Protocol18DefaultError(t *testing.T) {
+	var msg ReliableMessage
+	msg.Protocol = Protocol18
+	msg.ParamaterCount = 1
+	msg.Data = []byte{0x00, 0x01, 0x00}
+
+	_, err := DecodeReliableMessage(msg)
+
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestDecodeReliableMessage_Protocol18NestedError(t *testing.T) {
+	var msg ReliableMessage
+	msg.Protocol = Protocol18
+	msg.ParamaterCount = 1
+	msg.Data = []byte{0x00, Protocol18SliceObjectType, 0x01, 0x01}
+
+	_, err := DecodeReliableMessage(msg)
+
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestDecodeReliableMessage_Protocol18LengthError(t *testing.T) {
+	inputs := [][]byte{
+		{0x00, Protocol18SliceCompressedInt64Type, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		{0x00, Protocol18SliceInt16Type, 0x02, 0x01, 0x00, 0x02},
+		{0x00, Protocol18SliceFloat32Type, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		{0x00, Protocol18SliceDoubleType, 0x01, 0x00, 0x00, 0x00, 0x00},
+		{0x00, Protocol18SliceByteType, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		{0x00, Protocol18SliceBooleanType, 0x09, 0xff},
+		{0x00, Protocol18SliceStringType, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		{0x00, Protocol18StringType, 0xff, 0xff, 0xff, 0xff, 0x0f, 0x61},
+		{0x00, Protocol18SliceObjectType, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		{0x00, Protocol18HashtableType, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		{0x00, Protocol18DictionaryType, Protocol18Int32ZeroType, Protocol18Int32ZeroType, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		{0x00, Protocol18CustomType, 0x01, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		{0x00, Protocol18CustomSlimType + 1, 0x02, 0x00},
+		{0x00, Protocol18SliceHashtableType, 0xff, 0xff, 0xff, 0xff, 0x0f},
+	}
+
+	for _, input := range inputs {
+		var msg ReliableMessage
+		msg.Protocol = Protocol18
+		msg.ParamaterCount = 1
+		msg.Data = input
+
+		if _, err := DecodeReliableMessage(msg); err == nil {
+			t.Errorf("Expected an error decoding %v", input)
+		}
+	}
+}
+
+func TestDecodeReliableMessage_Protocol18TruncatedHeader(t *testing.T) {
+	inputs := [][]byte{
+		{0x00, Protocol18DictionaryType, 0x00, Protocol18SliceType},
+		{0x00, Protocol18DictionaryType, 0x00, Protocol18DictionaryType, 0x00},
+		{0x00, Protocol18SliceDictionaryType, 0x00, Protocol18SliceType, Protocol18SliceType},
+		{0x00, Protocol18DictionaryType},
+	}
+
+	for _, input := range inputs {
+		var msg ReliableMessage
+		msg.Protocol = Protocol18
+		msg.ParamaterCount = 1
+		msg.Data = input
+
+		if _, err := DecodeReliableMessage(msg); err == nil {
+			t.Errorf("Expected an error decoding %v", input)
+		}
+	}
+
+	command := PhotonCommand{
+		Type: SendReliableType,
+		Data: []byte{0xf3, OperationResponse, 0x01, 0x00, 0x00, Protocol18DictionaryType, 0x00, Protocol18SliceType},
+	}
+
+	if _, err := command.ReliableMessageWithProtocol(Protocol18); err == nil {
+		t.Errorf("Expected a truncated debug message to error")
+	}
+}
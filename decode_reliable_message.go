@@ -4,78 +4,205 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"reflect"
 	"strconv"
 )
 
 const (
-	NilType       = 42
-	Int8Type      = 98
-	Float32Type   = 102
-	Int32Type     = 105
-	Int16Type     = 107
-	Int64Type     = 108
-	StringType    = 115
-	BooleanType   = 111
-	SliceInt8Type = 120
-	SliceType     = 121
+	NilType                     = 42
+	ByteType                    = 98
+	DoubleType                  = 100
+	Float32Type                 = 102
+	Int32Type                   = 105
+	Int16Type                   = 107
+	Int64Type                   = 108
+	StringType                  = 115
+	BooleanType                 = 111
+	SliceInt8Type               = 120
+	SliceType                   = 121
+	SliceInt32Type              = 110
+	SliceStringType             = 97
+	SliceObjectType             = 122
+	HashtableType               = 104
+	DictionaryType              = 68
+	NestedEventDataType         = 101
+	NestedOperationRequestType  = 113
+	NestedOperationResponseType = 112
+
+	// Deprecated: Photon bytes are unsigned and decode as uint8, use ByteType.
+	Int8Type = ByteType
 )
 
 type ReliableMessageParamaters map[string]interface{}
 
+// An event embedded as a parameter value.
+type EventDataValue struct {
+	Code       uint8
+	Paramaters ReliableMessageParamaters
+}
+
+// An operation request embedded as a parameter value.
+type OperationRequestValue struct {
+	Code       uint8
+	Paramaters ReliableMessageParamaters
+}
+
+// An operation response embedded as a parameter value.
+type OperationResponseValue struct {
+	Code         uint8
+	ReturnCode   int16
+	DebugMessage interface{}
+	Paramaters   ReliableMessageParamaters
+}
+
+// A paramater of a message, along with the type it was serialized as in the
+// message's protocol.
+type Paramater struct {
+	ID    uint8
+	Type  uint8
+	Value interface{}
+}
+
+// The paramaters of a message in the order they were serialized.
+type Paramaters []Paramater
+
+// Returns the value of the last paramater with the given ID.
+func (p Paramaters) Get(id uint8) (interface{}, bool) {
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i].ID == id {
+			return p[i].Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// Returns the paramaters keyed by their IDs formatted in decimal, as returned
+// by DecodeReliableMessage. Nil values are left out.
+func (p Paramaters) Map() ReliableMessageParamaters {
+	params := make(map[string]interface{}, len(p))
+
+	for _, param := range p {
+		if param.Value != nil {
+			params[strconv.Itoa(int(param.ID))] = param.Value
+		}
+	}
+
+	return params
+}
+
 // Converts the paramaters of a reliable message into a hash situable for use in
-// hashmap.
+// hashmap. Messages read with Protocol18 are decoded with that protocol's type
+// table, anything else is treated as Protocol16.
 func DecodeReliableMessage(msg ReliableMessage) (ReliableMessageParamaters, error) {
-	buf := bytes.NewBuffer(msg.Data)
-	params := make(map[string]interface{})
-
-	for i := 0; i < int(msg.ParamaterCount); i++ {
-		var paramID uint8
-		var paramType uint8
-
-		binary.Read(buf, binary.BigEndian, &paramID)
-		binary.Read(buf, binary.BigEndian, &paramType)
-
-		paramsKey := strconv.Itoa(int(paramID))
-
-		switch paramType {
-		case NilType, 0:
-			// Do nothing
-		case Int8Type:
-			params[paramsKey] = decodeInt8Type(buf)
-		case Float32Type:
-			params[paramsKey] = decodeFloat32Type(buf)
-		case Int32Type:
-			params[paramsKey] = decodeInt32Type(buf)
-		case Int16Type, 7:
-			params[paramsKey] = decodeInt16Type(buf)
-		case Int64Type:
-			params[paramsKey] = decodeInt64Type(buf)
-		case StringType:
-			params[paramsKey] = decodeStringType(buf)
-		case BooleanType:
-			result, err := decodeBooleanType(buf)
+	params, err := DecodeReliableMessageParamaters(msg)
 
-			if err != nil {
-				return nil, err
-			}
+	if err != nil {
+		return nil, err
+	}
 
-			params[paramsKey] = result
-		case SliceInt8Type:
-			params[paramsKey] = decodeSliceInt8Type(buf)
-		case SliceType:
-			array, error := decodeSlice(buf)
-			if error != nil {
-				return nil, fmt.Errorf("Slice Error: %s; Current Params: %+v", error.Error(), params)
-			}
-			params[paramsKey] = array
-		default:
-			return nil, fmt.Errorf("Invalid type of %d; Current Params: %+v", paramType, params)
+	return params.Map(), nil
+}
+
+// Decodes the paramaters of a reliable message in the order they were
+// serialized, keeping nil values.
+func DecodeReliableMessageParamaters(msg ReliableMessage) (Paramaters, error) {
+	if msg.Protocol == Protocol18 {
+		return decodeParamaterList(bytes.NewBuffer(msg.Data), int(msg.ParamaterCount), decodeProtocol18Type)
+	}
+
+	return decodeParamaterList(bytes.NewBuffer(msg.Data), int(msg.ParamaterCount), decodeType)
+}
+
+func decodeParamaters(buf *bytes.Buffer, count int) (ReliableMessageParamaters, error) {
+	params, err := decodeParamaterList(buf, count, decodeType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return params.Map(), nil
+}
+
+// Reads each paramater's ID and type, decoding its value with the given
+// protocol's decoder.
+func decodeParamaterList(buf *bytes.Buffer, count int, decode func(*bytes.Buffer, uint8) (interface{}, error)) (Paramaters, error) {
+	var params Paramaters
+
+	for i := 0; i < count; i++ {
+		var param Paramater
+
+		binary.Read(buf, binary.BigEndian, &param.ID)
+		binary.Read(buf, binary.BigEndian, &param.Type)
+
+		result, err := decode(buf, param.Type)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s; Current Params: %+v", err.Error(), params.Map())
 		}
+
+		param.Value = result
+		params = append(params, param)
 	}
 
 	return params, nil
 }
 
+// Decodes a single value of the given type from the buffer.
+func decodeType(buf *bytes.Buffer, paramType uint8) (interface{}, error) {
+	switch paramType {
+	case NilType, 0:
+		return nil, nil
+	case ByteType:
+		return decodeByteType(buf), nil
+	case DoubleType:
+		return decodeDoubleType(buf), nil
+	case Float32Type:
+		return decodeFloat32Type(buf), nil
+	case Int32Type:
+		return decodeInt32Type(buf), nil
+	case Int16Type, 7:
+		return decodeInt16Type(buf), nil
+	case Int64Type:
+		return decodeInt64Type(buf), nil
+	case StringType:
+		return decodeStringType(buf)
+	case BooleanType:
+		return decodeBooleanType(buf)
+	case SliceInt8Type:
+		return decodeSliceInt8Type(buf)
+	case SliceType:
+		array, err := decodeSlice(buf)
+		if err != nil {
+			return nil, fmt.Errorf("Slice Error: %s", err.Error())
+		}
+		return array, nil
+	case HashtableType:
+		return decodeHashtableType(buf)
+	case DictionaryType:
+		return decodeDictionaryType(buf)
+	case CustomType:
+		var code uint8
+
+		binary.Read(buf, binary.BigEndian, &code)
+		return decodeProtocol16CustomType(buf, code)
+	case SliceInt32Type:
+		return decodeSliceInt32Type(buf)
+	case SliceStringType:
+		return decodeSliceStringType(buf)
+	case SliceObjectType:
+		return decodeSliceObjectType(buf)
+	case NestedEventDataType:
+		return decodeEventDataValue(buf)
+	case NestedOperationRequestType:
+		return decodeOperationRequestValue(buf)
+	case NestedOperationResponseType:
+		return decodeOperationResponseValue(buf)
+	default:
+		return nil, fmt.Errorf("Invalid type of %d", paramType)
+	}
+}
+
 func decodeSlice(buf *bytes.Buffer) (interface{}, error) {
 	var length uint16
 	var sliceType uint8
@@ -83,7 +210,27 @@ func decodeSlice(buf *bytes.Buffer) (interface{}, error) {
 	binary.Read(buf, binary.BigEndian, &length)
 	binary.Read(buf, binary.BigEndian, &sliceType)
 
+	if err := checkLength(buf, int(length), protocol16ElementSize(sliceType)); err != nil {
+		return nil, err
+	}
+
 	switch sliceType {
+	case ByteType:
+		array := make([]uint8, length)
+
+		for j := 0; j < int(length); j++ {
+			array[j] = decodeByteType(buf)
+		}
+
+		return array, nil
+	case DoubleType:
+		array := make([]float64, length)
+
+		for j := 0; j < int(length); j++ {
+			array[j] = decodeDoubleType(buf)
+		}
+
+		return array, nil
 	case Float32Type:
 		array := make([]float32, length)
 
@@ -122,7 +269,13 @@ func decodeSlice(buf *bytes.Buffer) (interface{}, error) {
 		array := make([]string, length)
 
 		for j := 0; j < int(length); j++ {
-			array[j] = decodeStringType(buf)
+			str, err := decodeStringType(buf)
+
+			if err != nil {
+				return nil, err
+			}
+
+			array[j] = str
 		}
 
 		return array, nil
@@ -144,7 +297,13 @@ func decodeSlice(buf *bytes.Buffer) (interface{}, error) {
 		array := make([][]int8, length)
 
 		for j := 0; j < int(length); j++ {
-			array[j] = decodeSliceInt8Type(buf)
+			element, err := decodeSliceInt8Type(buf)
+
+			if err != nil {
+				return nil, err
+			}
+
+			array[j] = element
 		}
 
 		return array, nil
@@ -161,13 +320,70 @@ func decodeSlice(buf *bytes.Buffer) (interface{}, error) {
 			array[j] = subArray
 		}
 
+		return array, nil
+	case HashtableType:
+		array := make([]map[interface{}]interface{}, length)
+
+		for j := 0; j < int(length); j++ {
+			hashtable, err := decodeHashtableType(buf)
+
+			if err != nil {
+				return nil, err
+			}
+
+			array[j] = hashtable
+		}
+
+		return array, nil
+	case DictionaryType:
+		var keyType uint8
+		var valueType uint8
+
+		binary.Read(buf, binary.BigEndian, &keyType)
+		binary.Read(buf, binary.BigEndian, &valueType)
+
+		array := make([]map[interface{}]interface{}, length)
+
+		for j := 0; j < int(length); j++ {
+			dictionary, err := decodeDictionaryEntries(buf, keyType, valueType)
+
+			if err != nil {
+				return nil, err
+			}
+
+			array[j] = dictionary
+		}
+
+		return array, nil
+	case CustomType:
+		var code uint8
+
+		binary.Read(buf, binary.BigEndian, &code)
+
+		array := make([]interface{}, length)
+
+		for j := 0; j < int(length); j++ {
+			value, err := decodeProtocol16CustomType(buf, code)
+
+			if err != nil {
+				return nil, err
+			}
+
+			array[j] = value
+		}
+
 		return array, nil
 	default:
 		return nil, fmt.Errorf("Invalid slice type of %d", sliceType)
 	}
 }
 
-func decodeInt8Type(buf *bytes.Buffer) (temp int8) {
+func decodeByteType(buf *bytes.Buffer) (temp uint8) {
+	binary.Read(buf, binary.BigEndian, &temp)
+	return
+}
+
+func decodeDoubleType(buf *bytes.Buffer) (temp float64) {
 	binary.Read(buf, binary.BigEndian, &temp)
 	return
 }
@@ -192,15 +408,40 @@ func decodeInt64Type(buf *bytes.Buffer) (temp int64) {
 	return
 }
 
-func decodeStringType(buf *bytes.Buffer) string {
+func decodeStringType(buf *bytes.Buffer) (string, error) {
 	var length uint16
 
 	binary.Read(buf, binary.BigEndian, &length)
 
+	if err := checkLength(buf, int(length), 1); err != nil {
+		return "", err
+	}
+
 	strBytes := make([]byte, length)
 	buf.Read(strBytes)
 
-	return string(strBytes[:])
+	return string(strBytes[:]), nil
+}
+
+func decodeProtocol16CustomType(buf *bytes.Buffer, code uint8) (interface{}, error) {
+	var length uint16
+
+	binary.Read(buf, binary.BigEndian, &length)
+
+	if err := checkLength(buf, int(length), 1); err != nil {
+		return nil, fmt.Errorf("Custom Type %d Error: %s", code, err.Error())
+	}
+
+	data := make([]byte, length)
+	buf.Read(data)
+
+	value, err := decodeCustomType(code, data)
+
+	if err != nil {
+		return nil, fmt.Errorf("Custom Type %d Error: %s", code, err.Error())
+	}
+
+	return value, nil
 }
 
 func decodeBooleanType(buf *bytes.Buffer) (bool, error) {
@@ -218,11 +459,15 @@ func decodeBooleanType(buf *bytes.Buffer) (bool, error) {
 
 }
 
-func decodeSliceInt8Type(buf *bytes.Buffer) []int8 {
+func decodeSliceInt8Type(buf *bytes.Buffer) ([]int8, error) {
 	var length uint32
 
 	binary.Read(buf, binary.BigEndian, &length)
 
+	if err := checkLength(buf, int(length), 1); err != nil {
+		return nil, err
+	}
+
 	array := make([]int8, length)
 
 	for j := 0; j < int(length); j++ {
@@ -231,5 +476,261 @@ func decodeSliceInt8Type(buf *bytes.Buffer) []int8 {
 		array[j] = temp
 	}
 
-	return array
+	return array, nil
+}
+
+// Decodes a hashtable, where every key and value is prefixed by its own type.
+func decodeHashtableType(buf *bytes.Buffer) (map[interface{}]interface{}, error) {
+	var size uint16
+
+	binary.Read(buf, binary.BigEndian, &size)
+
+	if err := checkLength(buf, int(size), 2); err != nil {
+		return nil, fmt.Errorf("Hashtable Error: %s", err.Error())
+	}
+
+	hashtable := make(map[interface{}]interface{}, size)
+
+	for j := 0; j < int(size); j++ {
+		var keyType uint8
+		var valueType uint8
+
+		binary.Read(buf, binary.BigEndian, &keyType)
+		key, err := decodeType(buf, keyType)
+
+		if err != nil {
+			return nil, fmt.Errorf("Hashtable Error: %s", err.Error())
+		}
+
+		binary.Read(buf, binary.BigEndian, &valueType)
+		value, err := decodeType(buf, valueType)
+
+		if err != nil {
+			return nil, fmt.Errorf("Hashtable Error: %s", err.Error())
+		}
+
+		if err := putMapEntry(hashtable, key, value); err != nil {
+			return nil, fmt.Errorf("Hashtable Error: %s", err.Error())
+		}
+	}
+
+	return hashtable, nil
+}
+
+// Decodes a dictionary. The key and value types are declared once up front; a
+// declared type of nil means each key or value carries its own type instead.
+func decodeDictionaryType(buf *bytes.Buffer) (map[interface{}]interface{}, error) {
+	var keyType uint8
+	var valueType uint8
+
+	binary.Read(buf, binary.BigEndian, &keyType)
+	binary.Read(buf, binary.BigEndian, &valueType)
+
+	return decodeDictionaryEntries(buf, keyType, valueType)
+}
+
+func decodeDictionaryEntries(buf *bytes.Buffer, keyType uint8, valueType uint8) (map[interface{}]interface{}, error) {
+	var size uint16
+
+	binary.Read(buf, binary.BigEndian, &size)
+
+	if err := checkLength(buf, int(size), 1); err != nil {
+		return nil, fmt.Errorf("Dictionary Error: %s", err.Error())
+	}
+
+	dictionary := make(map[interface{}]interface{}, size)
+
+	for j := 0; j < int(size); j++ {
+		entryKeyType := keyType
+		entryValueType := valueType
+
+		if entryKeyType == NilType || entryKeyType == 0 {
+			binary.Read(buf, binary.BigEndian, &entryKeyType)
+		}
+
+		key, err := decodeType(buf, entryKeyType)
+
+		if err != nil {
+			return nil, fmt.Errorf("Dictionary Error: %s", err.Error())
+		}
+
+		if entryValueType == NilType || entryValueType == 0 {
+			binary.Read(buf, binary.BigEndian, &entryValueType)
+		}
+
+		value, err := decodeType(buf, entryValueType)
+
+		if err != nil {
+			return nil, fmt.Errorf("Dictionary Error: %s", err.Error())
+		}
+
+		if err := putMapEntry(dictionary, key, value); err != nil {
+			return nil, fmt.Errorf("Dictionary Error: %s", err.Error())
+		}
+	}
+
+	return dictionary, nil
+}
+
+// Errors if a length read from the buffer claims more elements of the given
+// minimum size than the bytes remaining could hold, so that lengths from the
+// wire can't force large allocations.
+func checkLength(buf *bytes.Buffer, length int, size int) error {
+	if length < 0 || length > buf.Len()/size {
+		return fmt.Errorf("Length of %d exceeds the %d bytes remaining", length, buf.Len())
+	}
+
+	return nil
+}
+
+// Returns the fewest bytes an element of a Protocol16 slice of the given type
+// is serialized in.
+func protocol16ElementSize(sliceType uint8) int {
+	switch sliceType {
+	case DoubleType, Int64Type:
+		return 8
+	case Float32Type, Int32Type, SliceInt8Type:
+		return 4
+	case SliceType:
+		return 3
+	case Int16Type, StringType, HashtableType, CustomType:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func putMapEntry(m map[interface{}]interface{}, key interface{}, value interface{}) error {
+	if key != nil && !reflect.TypeOf(key).Comparable() {
+		return fmt.Errorf("Invalid key of type %T", key)
+	}
+
+	m[key] = value
+	return nil
+}
+
+func decodeSliceInt32Type(buf *bytes.Buffer) ([]int32, error) {
+	var length uint32
+
+	binary.Read(buf, binary.BigEndian, &length)
+
+	if err := checkLength(buf, int(length), 4); err != nil {
+		return nil, err
+	}
+
+	array := make([]int32, length)
+
+	for j := 0; j < int(length); j++ {
+		array[j] = decodeInt32Type(buf)
+	}
+
+	return array, nil
+}
+
+func decodeSliceStringType(buf *bytes.Buffer) ([]string, error) {
+	var length uint16
+
+	binary.Read(buf, binary.BigEndian, &length)
+
+	if err := checkLength(buf, int(length), 2); err != nil {
+		return nil, err
+	}
+
+	array := make([]string, length)
+
+	for j := 0; j < int(length); j++ {
+		str, err := decodeStringType(buf)
+
+		if err != nil {
+			return nil, err
+		}
+
+		array[j] = str
+	}
+
+	return array, nil
+}
+
+// Decodes an object array, where every element is prefixed by its own type.
+func decodeSliceObjectType(buf *bytes.Buffer) ([]interface{}, error) {
+	var length uint16
+
+	binary.Read(buf, binary.BigEndian, &length)
+
+	if err := checkLength(buf, int(length), 1); err != nil {
+		return nil, fmt.Errorf("Object Slice Error: %s", err.Error())
+	}
+
+	array := make([]interface{}, length)
+
+	for j := 0; j < int(length); j++ {
+		var elementType uint8
+
+		binary.Read(buf, binary.BigEndian, &elementType)
+		element, err := decodeType(buf, elementType)
+
+		if err != nil {
+			return nil, fmt.Errorf("Object Slice Error: %s", err.Error())
+		}
+
+		array[j] = element
+	}
+
+	return array, nil
+}
+
+func decodeEventDataValue(buf *bytes.Buffer) (event EventDataValue, err error) {
+	var count int16
+
+	binary.Read(buf, binary.BigEndian, &event.Code)
+	binary.Read(buf, binary.BigEndian, &count)
+
+	event.Paramaters, err = decodeParamaters(buf, int(count))
+
+	if err != nil {
+		err = fmt.Errorf("EventData Error: %s", err.Error())
+	}
+
+	return
+}
+
+func decodeOperationRequestValue(buf *bytes.Buffer) (request OperationRequestValue, err error) {
+	var count int16
+
+	binary.Read(buf, binary.BigEndian, &request.Code)
+	binary.Read(buf, binary.BigEndian, &count)
+
+	request.Paramaters, err = decodeParamaters(buf, int(count))
+
+	if err != nil {
+		err = fmt.Errorf("OperationRequest Error: %s", err.Error())
+	}
+
+	return
+}
+
+func decodeOperationResponseValue(buf *bytes.Buffer) (response OperationResponseValue, err error) {
+	var debugType uint8
+	var count int16
+
+	binary.Read(buf, binary.BigEndian, &response.Code)
+	binary.Read(buf, binary.BigEndian, &response.ReturnCode)
+	binary.Read(buf, binary.BigEndian, &debugType)
+
+	response.DebugMessage, err = decodeType(buf, debugType)
+
+	if err != nil {
+		err = fmt.Errorf("OperationResponse Error: %s", err.Error())
+		return
+	}
+
+	binary.Read(buf, binary.BigEndian, &count)
+
+	response.Paramaters, err = decodeParamaters(buf, int(count))
+
+	if err != nil {
+		err = fmt.Errorf("OperationResponse Error: %s", err.Error())
+	}
+
+	return
 }
@@ -10,8 +10,12 @@ var responses = []struct {
 	output ReliableMessageParamaters
 }{
 	{
-		[]byte{0x00, Int8Type, 0xff},
-		ReliableMessageParamaters{"0": int8(-1)},
+		[]byte{0x00, ByteType, 0xff},
+		ReliableMessageParamaters{"0": uint8(255)},
+	},
+	{
+		[]byte{0x00, DoubleType, 0x40, 0x60, 0x04, 0x18, 0x93, 0x74, 0xbc, 0x6a},
+		ReliableMessageParamaters{"0": float64(128.128)},
 	},
 	{
 		[]byte{0x00, Float32Type, 0x43, 0x00, 0x20, 0xc5},
@@ -81,6 +85,66 @@ var responses = []struct {
 		[]byte{0x00, SliceType, 0x00, 0x01, SliceType, 0x00, 0x01, BooleanType, 0x00},
 		ReliableMessageParamaters{"0": []interface{}{[]bool{false}}},
 	},
+	{
+		[]byte{0x00, SliceType, 0x00, 0x01, ByteType, 0xff},
+		ReliableMessageParamaters{"0": []uint8{255}},
+	},
+	{
+		[]byte{0x00, SliceType, 0x00, 0x01, DoubleType, 0x40, 0x60, 0x04, 0x18, 0x93, 0x74, 0xbc, 0x6a},
+		ReliableMessageParamaters{"0": []float64{128.128}},
+	},
+	{
+		[]byte{0x00, SliceInt32Type, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x80},
+		ReliableMessageParamaters{"0": []int32{128}},
+	},
+	{
+		[]byte{0x00, SliceStringType, 0x00, 0x02, 0x00, 0x01, 0x61, 0x00, 0x00},
+		ReliableMessageParamaters{"0": []string{"a", ""}},
+	},
+	{
+		[]byte{0x00, SliceObjectType, 0x00, 0x03, ByteType, 0x01, StringType, 0x00, 0x01, 0x61, NilType},
+		ReliableMessageParamaters{"0": []interface{}{uint8(1), "a", nil}},
+	},
+	{
+		[]byte{0x00, NestedEventDataType, 0x01, 0x00, 0x01, 0x02, ByteType, 0x03},
+		ReliableMessageParamaters{"0": EventDataValue{Code: 1, Paramaters: ReliableMessageParamaters{"2": uint8(3)}}},
+	},
+	{
+		[]byte{0x00, NestedOperationRequestType, 0x01, 0x00, 0x01, 0x02, ByteType, 0x03},
+		ReliableMessageParamaters{"0": OperationRequestValue{Code: 1, Paramaters: ReliableMessageParamaters{"2": uint8(3)}}},
+	},
+	{
+		[]byte{0x00, NestedOperationResponseType, 0x01, 0xff, 0xff, StringType, 0x00, 0x01, 0x61, 0x00, 0x01, 0x02, ByteType, 0x03},
+		ReliableMessageParamaters{"0": OperationResponseValue{Code: 1, ReturnCode: -1, DebugMessage: "a", Paramaters: ReliableMessageParamaters{"2": uint8(3)}}},
+	},
+	{
+		[]byte{0x00, NestedOperationResponseType, 0x01, 0x00, 0x00, NilType, 0x00, 0x00},
+		ReliableMessageParamaters{"0": OperationResponseValue{Code: 1, Paramaters: ReliableMessageParamaters{}}},
+	},
+	{
+		[]byte{0x00, HashtableType, 0x00, 0x01, ByteType, 0x01, StringType, 0x00, 0x03, 0x61, 0x62, 0x63},
+		ReliableMessageParamaters{"0": map[interface{}]interface{}{uint8(1): "abc"}},
+	},
+	{
+		[]byte{0x00, HashtableType, 0x00, 0x01, StringType, 0x00, 0x01, 0x61, HashtableType, 0x00, 0x00},
+		ReliableMessageParamaters{"0": map[interface{}]interface{}{"a": map[interface{}]interface{}{}}},
+	},
+	{
+		[]byte{0x00, DictionaryType, Int16Type, Int32Type, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x80},
+		ReliableMessageParamaters{"0": map[interface{}]interface{}{int16(128): int32(128)}},
+	},
+	{
+		[]byte{0x00, DictionaryType, NilType, 0x00, 0x00, 0x01, ByteType, 0x01, BooleanType, 0x01},
+		ReliableMessageParamaters{"0": map[interface{}]interface{}{uint8(1): true}},
+	},
+	{
+		[]byte{0x00, SliceType, 0x00, 0x01, HashtableType, 0x00, 0x01, ByteType, 0x01, ByteType, 0x02},
+		ReliableMessageParamaters{"0": []map[interface{}]interface{}{{uint8(1): uint8(2)}}},
+	},
+	{
+		[]byte{0x00, SliceType, 0x00, 0x02, DictionaryType, ByteType, ByteType, 0x00, 0x01, 0x01, 0x02, 0x00, 0x00},
+		ReliableMessageParamaters{"0": []map[interface{}]interface{}{{uint8(1): uint8(2)}, {}}},
+	},
 }
 
 func TestDecodeReliableMessage(t *testing.T) {
@@ -161,3 +225,114 @@ func TestDecodeReliableMessage_SliceNestedError(t *testing.T) {
 	}
 
 }
+
+func TestDecodeReliableMessage_HashtableError(t *testing.T) {
+	var msg ReliableMessage
+	msg.ParamaterCount = 1
+	msg.Data = []byte{0x00, HashtableType, 0x00, 0x01, ByteType, 0x01, 64, 0x00}
+
+	_, err := DecodeReliableMessage(msg)
+
+	if err == nil {
+		t.Fail()
+	}
+
+}
+
+func TestDecodeReliableMessage_DictionaryKeyError(t *testing.T) {
+	var msg ReliableMessage
+	msg.ParamaterCount = 1
+	msg.Data = []byte{0x00, DictionaryType, SliceInt8Type, ByteType, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+	_, err := DecodeReliableMessage(msg)
+
+	if err == nil {
+		t.Fail()
+	}
+
+}
+
+func TestDecodeReliableMessage_ObjectSliceError(t *testing.T) {
+	var msg ReliableMessage
+	msg.ParamaterCount = 1
+	msg.Data = []byte{0x00, SliceObjectType, 0x00, 0x01, 64, 0x00}
+
+	_, err := DecodeReliableMessage(msg)
+
+	if err == nil {
+		t.Fail()
+	}
+
+}
+
+func TestDecodeReliableMessage_NestedMessageError(t *testing.T) {
+	var msg ReliableMessage
+	msg.ParamaterCount = 1
+	msg.Data = []byte{0x00, NestedEventDataType, 0x01, 0x00, 0x01, 0x02, 64, 0x00}
+
+	_, err := DecodeReliableMessage(msg)
+
+	if err == nil {
+		t.Fail()
+	}
+
+}
+
+func TestDecodeReliableMessageParamaters(t *testing.T) {
+	var msg ReliableMessage
+	msg.ParamaterCount = 3
+	msg.Data = []byte{0xfc, Int16Type, 0x00, 0x05, 0x01, NilType, 0x00, StringType, 0x00, 0x01, 0x61}
+
+	params, err := DecodeReliableMessageParamaters(msg)
+
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	expected := Paramaters{
+		{ID: 252, Type: Int16Type, Value: int16(5)},
+		{ID: 1, Type: NilType, Value: nil},
+		{ID: 0, Type: StringType, Value: "a"},
+	}
+
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("Expected %v but got %v", expected, params)
+	}
+
+	if value, ok := params.Get(252); !ok || value != int16(5) {
+		t.Errorf("Get invalid")
+	}
+
+	if _, ok := params.Get(2); ok {
+		t.Errorf("Get of a missing paramater should fail")
+	}
+
+	if !reflect.DeepEqual(params.Map(), ReliableMessageParamaters{"252": int16(5), "0": "a"}) {
+		t.Errorf("Map invalid: %v", params.Map())
+	}
+}
+
+func TestDecodeReliableMessage_LengthError(t *testing.T) {
+	inputs := [][]byte{
+		{0x00, SliceInt32Type, 0xff, 0xff, 0xff, 0xff},
+		{0x00, SliceInt32Type, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01},
+		{0x00, SliceInt8Type, 0xff, 0xff, 0xff, 0xff},
+		{0x00, StringType, 0xff, 0xff, 0x61},
+		{0x00, SliceStringType, 0xff, 0xff},
+		{0x00, SliceObjectType, 0xff, 0xff},
+		{0x00, SliceType, 0x00, 0x02, Int64Type, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+		{0x00, HashtableType, 0xff, 0xff},
+		{0x00, DictionaryType, Int32Type, Int32Type, 0xff, 0xff},
+		{0x00, CustomType, 0x01, 0xff, 0xff},
+	}
+
+	for _, input := range inputs {
+		var msg ReliableMessage
+		msg.ParamaterCount = 1
+		msg.Data = input
+
+		if _, err := DecodeReliableMessage(msg); err == nil {
+			t.Errorf("Expected an error decoding %v", input)
+		}
+	}
+}
@@ -0,0 +1,480 @@
+package photon_spectator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Implemented by values which serialize themselves as a custom type.
+type CustomTypeMarshaler interface {
+	MarshalPhotonCustomType() (code uint8, data []byte, err error)
+}
+
+func (v CustomTypeValue) MarshalPhotonCustomType() (uint8, []byte, error) {
+	return v.Code, v.Data, nil
+}
+
+// Entries serialized as a dictionary, whose key and value types are declared
+// once up front. A declared type of NilType lets each key or value carry its
+// own type instead. Dictionaries decode as map[interface{}]interface{}.
+type Dictionary struct {
+	KeyType   uint8
+	ValueType uint8
+	Entries   map[interface{}]interface{}
+}
+
+var customTypeMarshalerType = reflect.TypeOf((*CustomTypeMarshaler)(nil)).Elem()
+
+// Serializes a reliable message header and its paramaters into the body of a
+// SendReliableType command using Protocol16. The ParamaterCount and Data of the
+// message are ignored and derived from the paramaters instead.
+//
+// Paramaters take the types they decode as, along with Dictionary, slices of
+// Dictionary sharing their declared types, slices of a CustomTypeMarshaler
+// sharing their code and slices of slices, which decode as []interface{}.
+// Hashtable and dictionary entries are written in order of their serialized
+// keys.
+func EncodeReliableMessage(msg ReliableMessage, params ReliableMessageParamaters) ([]byte, error) {
+	if msg.Protocol != 0 && msg.Protocol != Protocol16 {
+		return nil, fmt.Errorf("Encoding protocol %d is not supported", msg.Protocol)
+	}
+
+	buf := new(bytes.Buffer)
+
+	binary.Write(buf, binary.BigEndian, msg.Signature)
+	binary.Write(buf, binary.BigEndian, msg.Type)
+
+	switch msg.Type {
+	case OperationRequest:
+		binary.Write(buf, binary.BigEndian, msg.OperationCode)
+	case EventDataType:
+		binary.Write(buf, binary.BigEndian, msg.EventCode)
+	case OperationResponse, otherOperationResponse:
+		binary.Write(buf, binary.BigEndian, msg.OperationCode)
+		binary.Write(buf, binary.BigEndian, msg.OperationResponseCode)
+		binary.Write(buf, binary.BigEndian, msg.OperationDebugByte)
+	}
+
+	if err := encodeParamaters(buf, params); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Writes the paramater count followed by each paramater in order of its ID.
+func encodeParamaters(buf *bytes.Buffer, params ReliableMessageParamaters) error {
+	ids := make([]int, 0, len(params))
+
+	for key := range params {
+		id, err := strconv.Atoi(key)
+
+		if err != nil || id < 0 || id > 255 {
+			return fmt.Errorf("Invalid paramater key of %q", key)
+		}
+
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	binary.Write(buf, binary.BigEndian, int16(len(ids)))
+
+	for _, id := range ids {
+		binary.Write(buf, binary.BigEndian, uint8(id))
+
+		if err := encodeType(buf, params[strconv.Itoa(id)]); err != nil {
+			return fmt.Errorf("Paramater %d Error: %s", id, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Writes the type of a value followed by the value itself.
+func encodeType(buf *bytes.Buffer, value interface{}) error {
+	paramType, err := typeOf(value)
+
+	if err != nil {
+		return err
+	}
+
+	binary.Write(buf, binary.BigEndian, paramType)
+
+	return encodeValue(buf, paramType, value)
+}
+
+// Returns the Protocol16 type a value is serialized as.
+func typeOf(value interface{}) (uint8, error) {
+	switch value.(type) {
+	case nil:
+		return NilType, nil
+	case uint8:
+		return ByteType, nil
+	case float64:
+		return DoubleType, nil
+	case float32:
+		return Float32Type, nil
+	case int32:
+		return Int32Type, nil
+	case int16:
+		return Int16Type, nil
+	case int64:
+		return Int64Type, nil
+	case string:
+		return StringType, nil
+	case bool:
+		return BooleanType, nil
+	case []int8:
+		return SliceInt8Type, nil
+	case []int32:
+		return SliceInt32Type, nil
+	case []string:
+		return SliceStringType, nil
+	case []interface{}:
+		return SliceObjectType, nil
+	case map[interface{}]interface{}:
+		return HashtableType, nil
+	case EventDataValue:
+		return NestedEventDataType, nil
+	case OperationRequestValue:
+		return NestedOperationRequestType, nil
+	case OperationResponseValue:
+		return NestedOperationResponseType, nil
+	case Dictionary:
+		return DictionaryType, nil
+	case CustomTypeMarshaler:
+		return CustomType, nil
+	case []uint8, []float64, []float32, []int16, []int64, []bool, [][]int8, []map[interface{}]interface{}, []Dictionary:
+		return SliceType, nil
+	}
+
+	if t := reflect.TypeOf(value); t.Kind() == reflect.Slice && (t.Elem().Kind() == reflect.Slice || t.Elem().Implements(customTypeMarshalerType)) {
+		return SliceType, nil
+	}
+
+	return 0, fmt.Errorf("Invalid value of type %T", value)
+}
+
+// Writes a value of the given type without its type prefix.
+func encodeValue(buf *bytes.Buffer, paramType uint8, value interface{}) error {
+	switch paramType {
+	case NilType:
+		// Do nothing
+	case ByteType, DoubleType, Float32Type, Int32Type, Int16Type, Int64Type, BooleanType:
+		binary.Write(buf, binary.BigEndian, value)
+	case StringType:
+		return encodeStringType(buf, value.(string))
+	case SliceInt8Type:
+		array := value.([]int8)
+		binary.Write(buf, binary.BigEndian, uint32(len(array)))
+		binary.Write(buf, binary.BigEndian, array)
+	case SliceInt32Type:
+		array := value.([]int32)
+		binary.Write(buf, binary.BigEndian, uint32(len(array)))
+		binary.Write(buf, binary.BigEndian, array)
+	case SliceStringType:
+		array := value.([]string)
+
+		if err := encodeLength(buf, len(array)); err != nil {
+			return err
+		}
+
+		for _, str := range array {
+			if err := encodeStringType(buf, str); err != nil {
+				return err
+			}
+		}
+	case SliceObjectType:
+		array := value.([]interface{})
+
+		if err := encodeLength(buf, len(array)); err != nil {
+			return err
+		}
+
+		for _, element := range array {
+			if err := encodeType(buf, element); err != nil {
+				return fmt.Errorf("Object Slice Error: %s", err.Error())
+			}
+		}
+	case SliceType:
+		return encodeSlice(buf, value)
+	case HashtableType:
+		return encodeHashtableType(buf, value.(map[interface{}]interface{}))
+	case DictionaryType:
+		dictionary := value.(Dictionary)
+		binary.Write(buf, binary.BigEndian, dictionary.KeyType)
+		binary.Write(buf, binary.BigEndian, dictionary.ValueType)
+		return encodeDictionaryEntries(buf, dictionary)
+	case NestedEventDataType:
+		event := value.(EventDataValue)
+		binary.Write(buf, binary.BigEndian, event.Code)
+		return encodeParamaters(buf, event.Paramaters)
+	case NestedOperationRequestType:
+		request := value.(OperationRequestValue)
+		binary.Write(buf, binary.BigEndian, request.Code)
+		return encodeParamaters(buf, request.Paramaters)
+	case NestedOperationResponseType:
+		response := value.(OperationResponseValue)
+		binary.Write(buf, binary.BigEndian, response.Code)
+		binary.Write(buf, binary.BigEndian, response.ReturnCode)
+
+		if err := encodeType(buf, response.DebugMessage); err != nil {
+			return fmt.Errorf("OperationResponse Error: %s", err.Error())
+		}
+
+		return encodeParamaters(buf, response.Paramaters)
+	case CustomType:
+		code, data, err := value.(CustomTypeMarshaler).MarshalPhotonCustomType()
+
+		if err != nil {
+			return fmt.Errorf("Custom Type %d Error: %s", code, err.Error())
+		}
+
+		binary.Write(buf, binary.BigEndian, code)
+		return encodeBytes(buf, data)
+	default:
+		return fmt.Errorf("Invalid type of %d", paramType)
+	}
+
+	return nil
+}
+
+// Writes a slice whose elements all share a single type.
+func encodeSlice(buf *bytes.Buffer, value interface{}) error {
+	switch array := value.(type) {
+	case []uint8:
+		return encodeSliceHeader(buf, len(array), ByteType, array)
+	case []float64:
+		return encodeSliceHeader(buf, len(array), DoubleType, array)
+	case []float32:
+		return encodeSliceHeader(buf, len(array), Float32Type, array)
+	case []int16:
+		return encodeSliceHeader(buf, len(array), Int16Type, array)
+	case []int64:
+		return encodeSliceHeader(buf, len(array), Int64Type, array)
+	case []bool:
+		return encodeSliceHeader(buf, len(array), BooleanType, array)
+	case [][]int8:
+		if err := encodeSliceHeader(buf, len(array), SliceInt8Type, nil); err != nil {
+			return err
+		}
+
+		for _, element := range array {
+			encodeValue(buf, SliceInt8Type, element)
+		}
+	case []map[interface{}]interface{}:
+		if err := encodeSliceHeader(buf, len(array), HashtableType, nil); err != nil {
+			return err
+		}
+
+		for _, element := range array {
+			if err := encodeHashtableType(buf, element); err != nil {
+				return err
+			}
+		}
+	case []Dictionary:
+		if err := encodeSliceHeader(buf, len(array), DictionaryType, nil); err != nil {
+			return err
+		}
+
+		var declared Dictionary
+
+		if len(array) > 0 {
+			declared = array[0]
+		}
+
+		binary.Write(buf, binary.BigEndian, declared.KeyType)
+		binary.Write(buf, binary.BigEndian, declared.ValueType)
+
+		for _, element := range array {
+			if element.KeyType != declared.KeyType || element.ValueType != declared.ValueType {
+				return fmt.Errorf("Dictionaries of a slice must share their declared types")
+			}
+
+			if err := encodeDictionaryEntries(buf, element); err != nil {
+				return err
+			}
+		}
+	default:
+		return encodeReflectedSlice(buf, reflect.ValueOf(value))
+	}
+
+	return nil
+}
+
+// Writes a slice of slices or of custom types, which can't be matched by a
+// type switch.
+func encodeReflectedSlice(buf *bytes.Buffer, array reflect.Value) error {
+	if array.Kind() != reflect.Slice {
+		return fmt.Errorf("Invalid slice of type %s", array.Type())
+	}
+
+	if array.Type().Elem().Implements(customTypeMarshalerType) {
+		codes := make([]uint8, array.Len())
+		elements := make([][]byte, array.Len())
+
+		for i := range elements {
+			code, data, err := array.Index(i).Interface().(CustomTypeMarshaler).MarshalPhotonCustomType()
+
+			if err != nil {
+				return fmt.Errorf("Custom Type %d Error: %s", code, err.Error())
+			}
+
+			if i > 0 && code != codes[0] {
+				return fmt.Errorf("Custom types of a slice must share their code")
+			}
+
+			codes[i], elements[i] = code, data
+		}
+
+		if err := encodeSliceHeader(buf, len(elements), CustomType, nil); err != nil {
+			return err
+		}
+
+		if len(codes) == 0 {
+			codes = []uint8{0}
+		}
+
+		binary.Write(buf, binary.BigEndian, codes[0])
+
+		for _, data := range elements {
+			if err := encodeBytes(buf, data); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := encodeSliceHeader(buf, array.Len(), SliceType, nil); err != nil {
+		return err
+	}
+
+	for i := 0; i < array.Len(); i++ {
+		if err := encodeSlice(buf, array.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writes the length and element type of a slice, followed by the fixed size
+// elements when given.
+func encodeSliceHeader(buf *bytes.Buffer, length int, sliceType uint8, elements interface{}) error {
+	if err := encodeLength(buf, length); err != nil {
+		return err
+	}
+
+	binary.Write(buf, binary.BigEndian, sliceType)
+
+	if elements != nil {
+		binary.Write(buf, binary.BigEndian, elements)
+	}
+
+	return nil
+}
+
+func encodeHashtableType(buf *bytes.Buffer, hashtable map[interface{}]interface{}) error {
+	if err := encodeEntries(buf, hashtable, NilType, NilType); err != nil {
+		return fmt.Errorf("Hashtable Error: %s", err.Error())
+	}
+
+	return nil
+}
+
+func encodeDictionaryEntries(buf *bytes.Buffer, dictionary Dictionary) error {
+	if err := encodeEntries(buf, dictionary.Entries, dictionary.KeyType, dictionary.ValueType); err != nil {
+		return fmt.Errorf("Dictionary Error: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Writes the size of a hashtable or dictionary followed by its entries. Map
+// iteration is random, so entries are ordered by their serialized keys to
+// serialize equal maps the same way.
+func encodeEntries(buf *bytes.Buffer, entries map[interface{}]interface{}, keyType uint8, valueType uint8) error {
+	type entry struct {
+		key   []byte
+		value interface{}
+	}
+
+	if err := encodeLength(buf, len(entries)); err != nil {
+		return err
+	}
+
+	ordered := make([]entry, 0, len(entries))
+
+	for key, value := range entries {
+		encoded := new(bytes.Buffer)
+
+		if err := encodeDeclaredType(encoded, keyType, key); err != nil {
+			return err
+		}
+
+		ordered = append(ordered, entry{encoded.Bytes(), value})
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return bytes.Compare(ordered[i].key, ordered[j].key) < 0
+	})
+
+	for _, e := range ordered {
+		buf.Write(e.key)
+
+		if err := encodeDeclaredType(buf, valueType, e.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writes a value of a dictionary, along with its type unless the dictionary
+// declares one. Errors if the value isn't of the declared type.
+func encodeDeclaredType(buf *bytes.Buffer, declared uint8, value interface{}) error {
+	if declared == NilType || declared == 0 {
+		return encodeType(buf, value)
+	}
+
+	paramType, err := typeOf(value)
+
+	if err != nil {
+		return err
+	}
+
+	if paramType != declared {
+		return fmt.Errorf("Value of type %d doesn't match the declared type of %d", paramType, declared)
+	}
+
+	return encodeValue(buf, paramType, value)
+}
+
+func encodeStringType(buf *bytes.Buffer, str string) error {
+	return encodeBytes(buf, []byte(str))
+}
+
+func encodeBytes(buf *bytes.Buffer, data []byte) error {
+	if err := encodeLength(buf, len(data)); err != nil {
+		return err
+	}
+
+	buf.Write(data)
+	return nil
+}
+
+// Writes a uint16 length prefix, erroring if the length doesn't fit.
+func encodeLength(buf *bytes.Buffer, length int) error {
+	if length > 0xffff {
+		return fmt.Errorf("Invalid length of %d", length)
+	}
+
+	binary.Write(buf, binary.BigEndian, uint16(length))
+	return nil
+}
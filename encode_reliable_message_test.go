@@ -0,0 +1,336 @@
+package photon_spectator
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// Generates a random value of any type the decoder produces, nesting
+// containers up to the given depth.
+func randomValue(r *rand.Rand, depth int) interface{} {
+	kinds := 17
+
+	if depth > 0 {
+		kinds = 26
+	}
+
+	switch r.Intn(kinds) {
+	case 0:
+		return uint8(r.Intn(256))
+	case 1:
+		return r.Float64()
+	case 2:
+		return r.Float32()
+	case 3:
+		return r.Int31() - r.Int31()
+	case 4:
+		return int16(r.Intn(65536) - 32768)
+	case 5:
+		return r.Int63() - r.Int63()
+	case 6:
+		return randomString(r)
+	case 7:
+		return r.Intn(2) == 1
+	case 8:
+		return []int8{int8(r.Intn(256) - 128), int8(r.Intn(256) - 128)}
+	case 9:
+		return []int32{r.Int31(), -r.Int31()}
+	case 10:
+		return []string{randomString(r), randomString(r)}
+	case 11:
+		return []uint8{uint8(r.Intn(256))}
+	case 12:
+		return []float64{r.Float64(), r.Float64()}
+	case 13:
+		return []float32{r.Float32()}
+	case 14:
+		return []int16{int16(r.Intn(65536) - 32768)}
+	case 15:
+		return []int64{r.Int63(), -r.Int63()}
+	case 16:
+		return []bool{r.Intn(2) == 1, r.Intn(2) == 1, r.Intn(2) == 1}
+	case 17:
+		return [][]int8{{int8(r.Intn(256) - 128)}, {}}
+	case 18:
+		return []interface{}{randomValue(r, depth-1), randomValue(r, depth-1)}
+	case 19:
+		return randomHashtable(r, depth-1)
+	case 20:
+		return []map[interface{}]interface{}{randomHashtable(r, depth-1)}
+	case 21:
+		return Dictionary{NilType, NilType, randomHashtable(r, depth-1)}
+	case 22:
+		return []Dictionary{randomDictionary(r), randomDictionary(r)}
+	case 23:
+		return []CustomTypeValue{{Code: 200, Data: []byte(randomString(r))}, {Code: 200, Data: []byte{}}}
+	case 24:
+		return [][]int16{{int16(r.Intn(65536) - 32768)}, {}}
+	default:
+		switch r.Intn(4) {
+		case 0:
+			return EventDataValue{Code: uint8(r.Intn(256)), Paramaters: randomParamaters(r, depth-1)}
+		case 1:
+			return OperationRequestValue{Code: uint8(r.Intn(256)), Paramaters: randomParamaters(r, depth-1)}
+		case 2:
+			return OperationResponseValue{Code: uint8(r.Intn(256)), ReturnCode: int16(r.Intn(100)), DebugMessage: randomString(r), Paramaters: randomParamaters(r, depth-1)}
+		default:
+			return CustomTypeValue{Code: uint8(r.Intn(256)), Data: []byte(randomString(r))}
+		}
+	}
+}
+
+func randomString(r *rand.Rand) string {
+	runes := make([]rune, r.Intn(8))
+
+	for i := range runes {
+		runes[i] = rune('a' + r.Intn(26))
+	}
+
+	return string(runes)
+}
+
+func randomHashtable(r *rand.Rand, depth int) map[interface{}]interface{} {
+	hashtable := make(map[interface{}]interface{})
+
+	for i := r.Intn(4); i > 0; i-- {
+		hashtable[randomString(r)] = randomValue(r, depth)
+	}
+
+	hashtable[int32(r.Intn(100))] = randomValue(r, depth)
+
+	return hashtable
+}
+
+func randomDictionary(r *rand.Rand) Dictionary {
+	dictionary := Dictionary{Int32Type, StringType, make(map[interface{}]interface{})}
+
+	for i := r.Intn(4); i > 0; i-- {
+		dictionary.Entries[r.Int31()] = randomString(r)
+	}
+
+	return dictionary
+}
+
+// Returns the value a generated value decodes as.
+func decodedValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case Dictionary:
+		return decodedValue(v.Entries)
+	case []Dictionary:
+		array := make([]map[interface{}]interface{}, len(v))
+
+		for i, dictionary := range v {
+			array[i] = decodedValue(dictionary).(map[interface{}]interface{})
+		}
+
+		return array
+	case []CustomTypeValue:
+		array := make([]interface{}, len(v))
+
+		for i, custom := range v {
+			array[i] = custom
+		}
+
+		return array
+	case [][]int16:
+		array := make([]interface{}, len(v))
+
+		for i, element := range v {
+			array[i] = element
+		}
+
+		return array
+	case []interface{}:
+		array := make([]interface{}, len(v))
+
+		for i, element := range v {
+			array[i] = decodedValue(element)
+		}
+
+		return array
+	case []map[interface{}]interface{}:
+		array := make([]map[interface{}]interface{}, len(v))
+
+		for i, element := range v {
+			array[i] = decodedValue(element).(map[interface{}]interface{})
+		}
+
+		return array
+	case map[interface{}]interface{}:
+		hashtable := make(map[interface{}]interface{}, len(v))
+
+		for key, element := range v {
+			hashtable[key] = decodedValue(element)
+		}
+
+		return hashtable
+	case EventDataValue:
+		return EventDataValue{v.Code, decodedParamaters(v.Paramaters)}
+	case OperationRequestValue:
+		return OperationRequestValue{v.Code, decodedParamaters(v.Paramaters)}
+	case OperationResponseValue:
+		return OperationResponseValue{v.Code, v.ReturnCode, v.DebugMessage, decodedParamaters(v.Paramaters)}
+	default:
+		return value
+	}
+}
+
+func decodedParamaters(params ReliableMessageParamaters) ReliableMessageParamaters {
+	decoded := make(ReliableMessageParamaters, len(params))
+
+	for key, value := range params {
+		decoded[key] = decodedValue(value)
+	}
+
+	return decoded
+}
+
+func randomParamaters(r *rand.Rand, depth int) ReliableMessageParamaters {
+	params := make(ReliableMessageParamaters)
+
+	for i := r.Intn(6); i > 0; i-- {
+		params[strconv.Itoa(r.Intn(256))] = randomValue(r, depth)
+	}
+
+	return params
+}
+
+func TestEncodeReliableMessage_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(5056))
+	messageTypes := []uint8{OperationRequest, EventDataType, OperationResponse}
+
+	for i := 0; i < 1000; i++ {
+		var expected ReliableMessage
+		expected.Signature = 0xf3
+		expected.Type = messageTypes[r.Intn(len(messageTypes))]
+		expected.OperationCode = uint8(r.Intn(256))
+		expected.EventCode = uint8(r.Intn(256))
+		expected.OperationResponseCode = uint16(r.Intn(65536))
+		expected.OperationDebugByte = NilType
+
+		params := randomParamaters(r, 3)
+		data, err := EncodeReliableMessage(expected, params)
+
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		cmd := PhotonCommand{Type: SendReliableType, Data: data}
+		msg, _ := cmd.ReliableMessage()
+
+		if msg.Type != expected.Type || int(msg.ParamaterCount) != len(params) {
+			t.Fatalf("Expected header `%#v` but got `%#v`", expected, msg)
+		}
+
+		actual, err := DecodeReliableMessage(msg)
+
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		if !reflect.DeepEqual(decodedParamaters(params), actual) {
+			t.Fatalf("Expected `%#v` but got `%#v`", params, actual)
+		}
+	}
+}
+
+func TestEncodeReliableMessage_Bytes(t *testing.T) {
+	var msg ReliableMessage
+	msg.Signature = 0xf3
+	msg.Type = EventDataType
+	msg.EventCode = 1
+
+	params := ReliableMessageParamaters{
+		"1": int16(128),
+		"0": []string{"abc"},
+	}
+
+	expected := []byte{
+		0xf3, EventDataType, 0x01, 0x00, 0x02,
+		0x00, SliceStringType, 0x00, 0x01, 0x00, 0x03, 0x61, 0x62, 0x63,
+		0x01, Int16Type, 0x00, 0x80,
+	}
+
+	actual, err := EncodeReliableMessage(msg, params)
+
+	if err != nil {
+		t.Errorf("%s", err.Error())
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected `%#v` but got `%#v`", expected, actual)
+	}
+}
+
+func TestEncodeReliableMessage_Dictionary(t *testing.T) {
+	params := ReliableMessageParamaters{
+		"0": Dictionary{ByteType, NilType, map[interface{}]interface{}{uint8(2): "a", uint8(1): int16(3)}},
+	}
+
+	expected := []byte{
+		0xf3, EventDataType, 0x01, 0x00, 0x01,
+		0x00, DictionaryType, ByteType, NilType, 0x00, 0x02,
+		0x01, Int16Type, 0x00, 0x03,
+		0x02, StringType, 0x00, 0x01, 0x61,
+	}
+
+	actual, err := EncodeReliableMessage(ReliableMessage{Signature: 0xf3, Type: EventDataType, EventCode: 1}, params)
+
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected `%#v` but got `%#v`", expected, actual)
+	}
+
+	mismatched := Dictionary{ByteType, NilType, map[interface{}]interface{}{int16(1): nil}}
+
+	if _, err := EncodeReliableMessage(ReliableMessage{}, ReliableMessageParamaters{"0": mismatched}); err == nil {
+		t.Errorf("Expected keys not of the declared type to error")
+	}
+}
+
+func TestEncodeReliableMessage_Deterministic(t *testing.T) {
+	hashtable := make(map[interface{}]interface{})
+
+	for i := 0; i < 32; i++ {
+		hashtable[int32(i)] = strconv.Itoa(i)
+	}
+
+	expected, _ := EncodeReliableMessage(ReliableMessage{}, ReliableMessageParamaters{"0": hashtable})
+
+	for i := 0; i < 10; i++ {
+		actual, _ := EncodeReliableMessage(ReliableMessage{}, ReliableMessageParamaters{"0": hashtable})
+
+		if !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("Expected hashtables to encode the same way each time")
+		}
+	}
+}
+
+func TestEncodeReliableMessage_InvalidKey(t *testing.T) {
+	_, err := EncodeReliableMessage(ReliableMessage{}, ReliableMessageParamaters{"a": int16(1)})
+
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestEncodeReliableMessage_InvalidValue(t *testing.T) {
+	_, err := EncodeReliableMessage(ReliableMessage{}, ReliableMessageParamaters{"0": 1})
+
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestEncodeReliableMessage_InvalidProtocol(t *testing.T) {
+	_, err := EncodeReliableMessage(ReliableMessage{Protocol: Protocol18}, ReliableMessageParamaters{})
+
+	if err == nil {
+		t.Fail()
+	}
+}
@@ -1,63 +1,338 @@
 package photon_spectator
 
-import (	
+import (
+	"bytes"
+	"fmt"
+	"time"
+
 	lru "github.com/hashicorp/golang-lru"
 )
 
 // Provides a LRU backed buffer which will assemble ReliableFragments
 // into a single PhotonCommand with type ReliableMessage
 type FragmentBuffer struct {
-	cache *lru.Cache
+	cache       *lru.Cache
+	options     FragmentBufferOptions
+	bytes       int
+	now         time.Time
+	evictReason EvictionReason
+}
+
+// Limits on the contents of a FragmentBuffer. Zero values leave the corresponding limit unset,
+// apart from MaxEntries which defaults to 128.
+type FragmentBufferOptions struct {
+	// Messages buffered at once
+	MaxEntries int
+	// Bytes buffered at once, counting the full length of each incomplete message
+	MaxBytes int
+	// Time after which a message that hasn't received a fragment is dropped, as measured by the
+	// times passed to Advance
+	Timeout time.Duration
+	// Called with each incomplete message the buffer drops
+	OnEvict func(EvictedMessage)
+}
+
+// Why an incomplete message was dropped from a FragmentBuffer.
+type EvictionReason uint8
+
+const (
+	EvictedForEntries EvictionReason = iota
+	EvictedForBytes
+	EvictedForTimeout
+)
+
+// An incomplete message dropped from a FragmentBuffer.
+type EvictedMessage struct {
+	Connection        ConnectionKey
+	ChannelID         uint8
+	SequenceNumber    int32
+	FragmentCount     int
+	FragmentsReceived int
+	TotalLength       int
+	BytesReceived     int
+	LastSeen          time.Time
+	Reason            EvictionReason
+}
+
+type fragmentBufferKey struct {
+	connection     ConnectionKey
+	channelID      uint8
+	sequenceNumber int32
 }
 
 // Offers a message to the buffer. Returns nil when no new commands could be assembled from the
-// buffer's contents.
-func (buf *FragmentBuffer) Offer(msg ReliableFragment) *PhotonCommand {
-	var entry fragmentBufferEntry
-	
-	if buf.cache.Contains(msg.SequenceNumber) {
-		obj, _ := buf.cache.Get(msg.SequenceNumber)
-		entry = obj.(fragmentBufferEntry)
-		entry.Fragments[int(msg.FragmentNumber)] = msg.Data
-		
+// buffer's contents. Errors if the fragment is inconsistent with those already buffered for its
+// message, in which case the message is discarded.
+func (buf *FragmentBuffer) Offer(msg ReliableFragment) (*PhotonCommand, error) {
+	return buf.offer(fragmentBufferKey{sequenceNumber: msg.SequenceNumber}, msg, nil)
+}
+
+// Offers a message sent on the given connection and channel to the buffer. Fragments are only
+// assembled with others from the same connection and channel. Returns nil when no new commands
+// could be assembled from the buffer's contents. Errors if the fragment is inconsistent with those
+// already buffered for its message, in which case the message is discarded.
+func (buf *FragmentBuffer) OfferFrom(connection ConnectionKey, channelID uint8, msg ReliableFragment) (*PhotonCommand, error) {
+	return buf.offer(fragmentBufferKey{connection, channelID, msg.SequenceNumber}, msg, nil)
+}
+
+// Offers a SendReliableFragmentType command sent on the given connection to the buffer. The
+// assembled command carries the channel, flags and reserved byte of the message's first fragment,
+// and the message's start sequence number. Returns nil when no new commands could be assembled
+// from the buffer's contents. Errors if the command isn't a fragment or is inconsistent with those
+// already buffered for its message, in which case the message is discarded.
+func (buf *FragmentBuffer) OfferCommand(connection ConnectionKey, command PhotonCommand) (*PhotonCommand, error) {
+	msg, err := command.ReliableFragment()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.offer(fragmentBufferKey{connection, command.ChannelID, msg.SequenceNumber}, msg, &command)
+}
+
+func (buf *FragmentBuffer) offer(key fragmentBufferKey, msg ReliableFragment, origin *PhotonCommand) (*PhotonCommand, error) {
+	var entry *fragmentBufferEntry
+
+	if obj, ok := buf.cache.Get(key); ok {
+		entry = obj.(*fragmentBufferEntry)
 	} else {
-		entry.FragmentsNeeded = int(msg.FragmentCount)
-		entry.Fragments = make(map[int][]byte)
-		entry.Fragments[int(msg.FragmentNumber)] = msg.Data
+		var err error
+
+		if entry, err = newFragmentBufferEntry(msg); err != nil {
+			return nil, fmt.Errorf("Fragment %d of message %d: %s", msg.FragmentNumber, msg.SequenceNumber, err.Error())
+		}
+	}
+
+	if origin != nil && (entry.Origin == nil || msg.FragmentNumber == 0) {
+		entry.Origin = origin
+	}
+
+	if err := entry.Add(msg); err != nil {
+		entry.Done = true
+		buf.cache.Remove(key)
+		return nil, fmt.Errorf("Fragment %d of message %d: %s", msg.FragmentNumber, msg.SequenceNumber, err.Error())
 	}
-	
+
 	if entry.Finished() {
-		command := entry.Make()
-		buf.cache.Remove(msg.SequenceNumber)
-		return &command
-	} else {
-		buf.cache.Add(msg.SequenceNumber, entry)		
-		return nil
+		command := entry.Make(key)
+		entry.Done = true
+		buf.cache.Remove(key)
+		return &command, nil
+	}
+
+	entry.LastSeen = buf.now
+
+	if !buf.cache.Contains(key) {
+		buf.bytes += entry.TotalLength
+		buf.evictReason = EvictedForEntries
+		buf.cache.Add(key, entry)
+	}
+
+	if buf.options.MaxBytes > 0 {
+		buf.evictReason = EvictedForBytes
+
+		for buf.bytes > buf.options.MaxBytes && buf.cache.Len() > 0 {
+			buf.cache.RemoveOldest()
+		}
+	}
+
+	return nil, nil
+}
+
+// Sets the current time of the buffer, normally the capture time of the latest packet, and drops
+// messages which haven't received a fragment within the timeout.
+func (buf *FragmentBuffer) Advance(now time.Time) {
+	buf.now = now
+
+	if buf.options.Timeout <= 0 {
+		return
+	}
+
+	buf.evictReason = EvictedForTimeout
+
+	// Keys are ordered from least to most recently offered a fragment
+	for _, key := range buf.cache.Keys() {
+		obj, ok := buf.cache.Peek(key)
+
+		if !ok {
+			continue
+		}
+
+		if now.Sub(obj.(*fragmentBufferEntry).LastSeen) <= buf.options.Timeout {
+			break
+		}
+
+		buf.cache.Remove(key)
+	}
+}
+
+// Drops every message buffered for a connection without reporting it as evicted.
+func (buf *FragmentBuffer) Remove(connection ConnectionKey) {
+	for _, key := range buf.cache.Keys() {
+		if key.(fragmentBufferKey).connection != connection {
+			continue
+		}
+
+		if obj, ok := buf.cache.Peek(key); ok {
+			obj.(*fragmentBufferEntry).Done = true
+			buf.cache.Remove(key)
+		}
 	}
 }
 
+func (buf *FragmentBuffer) onEvicted(key interface{}, value interface{}) {
+	entry := value.(*fragmentBufferEntry)
+	buf.bytes -= entry.TotalLength
+
+	if entry.Done || buf.options.OnEvict == nil {
+		return
+	}
+
+	bufferKey := key.(fragmentBufferKey)
+
+	buf.options.OnEvict(EvictedMessage{
+		Connection:        bufferKey.connection,
+		ChannelID:         bufferKey.channelID,
+		SequenceNumber:    bufferKey.sequenceNumber,
+		FragmentCount:     entry.FragmentsNeeded,
+		FragmentsReceived: len(entry.Fragments),
+		TotalLength:       entry.TotalLength,
+		BytesReceived:     entry.Received,
+		LastSeen:          entry.LastSeen,
+		Reason:            buf.evictReason,
+	})
+}
+
+// Fragments are carried in a single UDP datagram, so can't hold more than its 16 bit length.
+const maxFragmentLength = 65535
+
+type fragmentRange struct {
+	Offset int
+	Data   []byte
+}
+
 type fragmentBufferEntry struct {
 	FragmentsNeeded int
-	Fragments       map[int][]byte
+	Fragments       map[int]fragmentRange
+	Received        int
+	TotalLength     int
+	LastSeen        time.Time
+	Done            bool
+	Origin          *PhotonCommand
+}
+
+// Errors if the message's total length couldn't be carried by its fragments. Fragments are kept
+// apart until the message is complete, so the total length isn't allocated up front.
+func newFragmentBufferEntry(msg ReliableFragment) (*fragmentBufferEntry, error) {
+	if msg.FragmentCount <= 0 {
+		return nil, fmt.Errorf("Invalid fragment count of %d", msg.FragmentCount)
+	}
+
+	if msg.TotalLength < 0 || int64(msg.TotalLength) > int64(msg.FragmentCount)*maxFragmentLength {
+		return nil, fmt.Errorf("Total length of %d can't be carried by %d fragments", msg.TotalLength, msg.FragmentCount)
+	}
+
+	entry := fragmentBufferEntry{
+		FragmentsNeeded: int(msg.FragmentCount),
+		Fragments:       make(map[int]fragmentRange),
+		TotalLength:     int(msg.TotalLength),
+	}
+
+	return &entry, nil
+}
+
+// Places a fragment at its offset. Errors if the fragment doesn't fit within the message, or
+// overlaps or conflicts with a fragment already added. Exact duplicates are ignored.
+func (buf *fragmentBufferEntry) Add(msg ReliableFragment) error {
+	number := int(msg.FragmentNumber)
+	offset := int(msg.FragmentOffset)
+	length := len(msg.Data)
+
+	if int(msg.FragmentCount) != buf.FragmentsNeeded {
+		return fmt.Errorf("Fragment count of %d doesn't match %d", msg.FragmentCount, buf.FragmentsNeeded)
+	}
+
+	if int(msg.TotalLength) != buf.TotalLength {
+		return fmt.Errorf("Total length of %d doesn't match %d", msg.TotalLength, buf.TotalLength)
+	}
+
+	if number < 0 || number >= buf.FragmentsNeeded {
+		return fmt.Errorf("Invalid fragment number of %d", number)
+	}
+
+	if offset < 0 || offset+length > buf.TotalLength {
+		return fmt.Errorf("Fragment at %d of length %d exceeds total length of %d", offset, length, buf.TotalLength)
+	}
+
+	if existing, ok := buf.Fragments[number]; ok {
+		if existing.Offset == offset && bytes.Equal(existing.Data, msg.Data) {
+			return nil
+		}
+
+		return fmt.Errorf("Conflicting duplicate fragment")
+	}
+
+	for other, existing := range buf.Fragments {
+		if offset < existing.Offset+len(existing.Data) && existing.Offset < offset+length {
+			return fmt.Errorf("Fragment overlaps fragment %d", other)
+		}
+	}
+
+	buf.Fragments[number] = fragmentRange{offset, append([]byte(nil), msg.Data...)}
+	buf.Received += length
+
+	if buf.Finished() {
+		return nil
+	}
+
+	if len(buf.Fragments) == buf.FragmentsNeeded {
+		return fmt.Errorf("Fragments cover %d of %d bytes", buf.Received, buf.TotalLength)
+	}
+
+	return nil
 }
 
-func (buf fragmentBufferEntry) Finished() bool {
-	return len(buf.Fragments) == buf.FragmentsNeeded
+func (buf *fragmentBufferEntry) Finished() bool {
+	return len(buf.Fragments) == buf.FragmentsNeeded && buf.Received == buf.TotalLength
 }
 
-func (buf fragmentBufferEntry) Make() PhotonCommand {
-	var data []byte
+func (buf *fragmentBufferEntry) Make(key fragmentBufferKey) PhotonCommand {
+	data := make([]byte, buf.TotalLength)
+
+	for _, fragment := range buf.Fragments {
+		copy(data[fragment.Offset:], fragment.Data)
+	}
+
+	command := PhotonCommand{
+		Type:                   SendReliableType,
+		ChannelID:              key.channelID,
+		Length:                 int32(PhotonCommandHeaderLength + len(data)),
+		ReliableSequenceNumber: key.sequenceNumber,
+		Data:                   data,
+	}
 
-	for i := 0; i < buf.FragmentsNeeded; i++ {
-		data = append(data, buf.Fragments[i]...)
+	if buf.Origin != nil {
+		command.Flags = buf.Origin.Flags
+		command.ReservedByte = buf.Origin.ReservedByte
 	}
 
-	return PhotonCommand{Type: SendReliableType, Data: data}
+	return command
 }
 
 // Makes a new instance of a FragmentBuffer
 func NewFragmentBuffer() *FragmentBuffer {
+	return NewFragmentBufferWithOptions(FragmentBufferOptions{})
+}
+
+// Makes a new instance of a FragmentBuffer with the given limits
+func NewFragmentBufferWithOptions(options FragmentBufferOptions) *FragmentBuffer {
 	var f FragmentBuffer
-	f.cache, _ = lru.New(128)
+
+	if options.MaxEntries <= 0 {
+		options.MaxEntries = 128
+	}
+
+	f.options = options
+	f.cache, _ = lru.NewWithEvict(options.MaxEntries, f.onEvicted)
 	return &f
 }
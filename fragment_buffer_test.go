@@ -3,36 +3,332 @@ package photon_spectator
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
 )
 
 func TestFragmentBuffer(t *testing.T) {
 	fragmentOne := ReliableFragment{
 		FragmentNumber: 0,
 		FragmentCount:  2,
+		TotalLength:    2,
+		FragmentOffset: 0,
 		Data:           []byte{0xca},
 	}
 
 	fragmentTwo := ReliableFragment{
 		FragmentNumber: 1,
 		FragmentCount:  2,
+		TotalLength:    2,
+		FragmentOffset: 1,
 		Data:           []byte{0xfe},
 	}
 
 	buffer := NewFragmentBuffer()
 
-	response := buffer.Offer(fragmentOne)
+	response, err := buffer.Offer(fragmentOne)
 
-	if response != nil {
+	if response != nil || err != nil {
 		t.Fail()
 	}
 
-	response = buffer.Offer(fragmentTwo)
+	response, err = buffer.Offer(fragmentTwo)
 
-	if response == nil {
-		t.Fail()
+	if response == nil || err != nil {
+		t.FailNow()
 	}
 
 	if !reflect.DeepEqual((*response).Data, []byte{0xca, 0xfe}) {
 		t.Fail()
 	}
 }
+
+func TestFragmentBuffer_OfferFrom(t *testing.T) {
+	clientOne := ConnectionKey{
+		Network:   gopacket.NewFlow(layers.EndpointIPv4, []byte{10, 0, 0, 1}, []byte{10, 0, 0, 3}),
+		Transport: gopacket.NewFlow(layers.EndpointUDPPort, []byte{0xc3, 0x50}, []byte{0x13, 0xc0}),
+		PeerID:    1,
+	}
+
+	clientTwo := clientOne
+	clientTwo.Network = gopacket.NewFlow(layers.EndpointIPv4, []byte{10, 0, 0, 2}, []byte{10, 0, 0, 3})
+
+	fragment := func(number int32, data byte) ReliableFragment {
+		return ReliableFragment{
+			SequenceNumber: 1,
+			FragmentNumber: number,
+			FragmentCount:  2,
+			TotalLength:    2,
+			FragmentOffset: number,
+			Data:           []byte{data},
+		}
+	}
+
+	buffer := NewFragmentBuffer()
+
+	if response, _ := buffer.OfferFrom(clientOne, 0, fragment(0, 0xca)); response != nil {
+		t.Fail()
+	}
+
+	if response, _ := buffer.OfferFrom(clientTwo, 0, fragment(1, 0xbe)); response != nil {
+		t.Fail()
+	}
+
+	if response, _ := buffer.OfferFrom(clientOne, 1, fragment(1, 0x00)); response != nil {
+		t.Fail()
+	}
+
+	response, _ := buffer.OfferFrom(clientTwo, 0, fragment(0, 0xba))
+
+	if response == nil || !reflect.DeepEqual(response.Data, []byte{0xba, 0xbe}) {
+		t.Errorf("Expected client two's fragments to be assembled")
+	}
+
+	response, _ = buffer.OfferFrom(clientOne, 0, fragment(1, 0xfe))
+
+	if response == nil || !reflect.DeepEqual(response.Data, []byte{0xca, 0xfe}) {
+		t.Errorf("Expected client one's fragments to be assembled")
+	}
+}
+
+func TestFragmentBuffer_Offsets(t *testing.T) {
+	fragments := []ReliableFragment{
+		{FragmentNumber: 1, FragmentCount: 3, TotalLength: 5, FragmentOffset: 2, Data: []byte{0x03, 0x04}},
+		{FragmentNumber: 1, FragmentCount: 3, TotalLength: 5, FragmentOffset: 2, Data: []byte{0x03, 0x04}},
+		{FragmentNumber: 2, FragmentCount: 3, TotalLength: 5, FragmentOffset: 4, Data: []byte{0x05}},
+		{FragmentNumber: 0, FragmentCount: 3, TotalLength: 5, FragmentOffset: 0, Data: []byte{0x01, 0x02}},
+	}
+
+	buffer := NewFragmentBuffer()
+
+	var response *PhotonCommand
+	var err error
+
+	for _, fragment := range fragments {
+		response, err = buffer.Offer(fragment)
+
+		if err != nil {
+			t.Errorf("%s", err.Error())
+		}
+	}
+
+	if response == nil || !reflect.DeepEqual(response.Data, []byte{0x01, 0x02, 0x03, 0x04, 0x05}) {
+		t.Errorf("Expected fragments to be assembled by offset")
+	}
+}
+
+func TestFragmentBuffer_Inconsistent(t *testing.T) {
+	first := ReliableFragment{FragmentNumber: 0, FragmentCount: 2, TotalLength: 4, FragmentOffset: 0, Data: []byte{0x01, 0x02}}
+
+	inconsistent := []ReliableFragment{
+		{FragmentNumber: 0, FragmentCount: 2, TotalLength: 4, FragmentOffset: 0, Data: []byte{0x01, 0x03}},
+		{FragmentNumber: 1, FragmentCount: 2, TotalLength: 4, FragmentOffset: 1, Data: []byte{0x02, 0x03}},
+		{FragmentNumber: 1, FragmentCount: 2, TotalLength: 4, FragmentOffset: 3, Data: []byte{0x03, 0x04}},
+		{FragmentNumber: 1, FragmentCount: 3, TotalLength: 4, FragmentOffset: 2, Data: []byte{0x03, 0x04}},
+		{FragmentNumber: 1, FragmentCount: 2, TotalLength: 5, FragmentOffset: 2, Data: []byte{0x03, 0x04}},
+		{FragmentNumber: 2, FragmentCount: 2, TotalLength: 4, FragmentOffset: 2, Data: []byte{0x03, 0x04}},
+		{FragmentNumber: 1, FragmentCount: 2, TotalLength: 4, FragmentOffset: 2, Data: []byte{0x03}},
+	}
+
+	for _, fragment := range inconsistent {
+		buffer := NewFragmentBuffer()
+		buffer.Offer(first)
+
+		response, err := buffer.Offer(fragment)
+
+		if response != nil || err == nil {
+			t.Errorf("Expected %#v to be rejected", fragment)
+		}
+	}
+}
+
+func TestFragmentBuffer_InvalidLength(t *testing.T) {
+	invalid := []ReliableFragment{
+		{FragmentNumber: 0, FragmentCount: 1, TotalLength: 0x7fffffff, Data: []byte{0x01}},
+		{FragmentNumber: 0, FragmentCount: 2, TotalLength: 2*65535 + 1, Data: []byte{0x01}},
+		{FragmentNumber: 0, FragmentCount: 2, TotalLength: -1, Data: []byte{0x01}},
+		{FragmentNumber: 0, FragmentCount: 0, TotalLength: 1, Data: []byte{0x01}},
+	}
+
+	for _, fragment := range invalid {
+		buffer := NewFragmentBuffer()
+		response, err := buffer.Offer(fragment)
+
+		if response != nil || err == nil || buffer.cache.Len() != 0 {
+			t.Errorf("Expected %#v to be rejected", fragment)
+		}
+	}
+}
+
+func TestFragmentBuffer_MaxEntries(t *testing.T) {
+	var evicted []EvictedMessage
+
+	buffer := NewFragmentBufferWithOptions(FragmentBufferOptions{
+		MaxEntries: 1,
+		OnEvict:    func(msg EvictedMessage) { evicted = append(evicted, msg) },
+	})
+
+	buffer.Offer(ReliableFragment{SequenceNumber: 1, FragmentCount: 2, TotalLength: 2, Data: []byte{0x01}})
+	buffer.Offer(ReliableFragment{SequenceNumber: 2, FragmentCount: 2, TotalLength: 2, Data: []byte{0x01}})
+
+	if len(evicted) != 1 {
+		t.Fatalf("Expected one eviction but got %d", len(evicted))
+	}
+
+	if evicted[0].SequenceNumber != 1 || evicted[0].Reason != EvictedForEntries {
+		t.Errorf("Eviction invalid: %#v", evicted[0])
+	}
+
+	if evicted[0].FragmentsReceived != 1 || evicted[0].BytesReceived != 1 {
+		t.Errorf("Eviction progress invalid: %#v", evicted[0])
+	}
+}
+
+func TestFragmentBuffer_MaxBytes(t *testing.T) {
+	var evicted []EvictedMessage
+
+	buffer := NewFragmentBufferWithOptions(FragmentBufferOptions{
+		MaxBytes: 5,
+		OnEvict:  func(msg EvictedMessage) { evicted = append(evicted, msg) },
+	})
+
+	buffer.Offer(ReliableFragment{SequenceNumber: 1, FragmentCount: 2, TotalLength: 3, Data: []byte{0x01}})
+	buffer.Offer(ReliableFragment{SequenceNumber: 2, FragmentCount: 2, TotalLength: 2, Data: []byte{0x01}})
+
+	if len(evicted) != 0 {
+		t.Fatalf("Expected no evictions but got %d", len(evicted))
+	}
+
+	buffer.Offer(ReliableFragment{SequenceNumber: 3, FragmentCount: 2, TotalLength: 3, Data: []byte{0x01}})
+
+	if len(evicted) != 1 || evicted[0].SequenceNumber != 1 || evicted[0].Reason != EvictedForBytes {
+		t.Fatalf("Eviction invalid: %#v", evicted)
+	}
+
+	// Completing a message releases its bytes without reporting it
+	response, _ := buffer.Offer(ReliableFragment{SequenceNumber: 2, FragmentNumber: 1, FragmentCount: 2, TotalLength: 2, FragmentOffset: 1, Data: []byte{0x02}})
+
+	if response == nil || len(evicted) != 1 {
+		t.Errorf("Expected message to complete")
+	}
+
+	buffer.Offer(ReliableFragment{SequenceNumber: 4, FragmentCount: 2, TotalLength: 2, Data: []byte{0x01}})
+
+	if len(evicted) != 1 {
+		t.Errorf("Expected no further evictions but got %#v", evicted)
+	}
+}
+
+func TestFragmentBuffer_Timeout(t *testing.T) {
+	var evicted []EvictedMessage
+
+	buffer := NewFragmentBufferWithOptions(FragmentBufferOptions{
+		Timeout: time.Second,
+		OnEvict: func(msg EvictedMessage) { evicted = append(evicted, msg) },
+	})
+
+	start := time.Unix(1500000000, 0)
+
+	buffer.Advance(start)
+	buffer.Offer(ReliableFragment{SequenceNumber: 1, FragmentCount: 2, TotalLength: 2, Data: []byte{0x01}})
+
+	buffer.Advance(start.Add(800 * time.Millisecond))
+	buffer.Offer(ReliableFragment{SequenceNumber: 2, FragmentCount: 2, TotalLength: 2, Data: []byte{0x01}})
+
+	buffer.Advance(start.Add(1500 * time.Millisecond))
+
+	if len(evicted) != 1 || evicted[0].SequenceNumber != 1 || evicted[0].Reason != EvictedForTimeout {
+		t.Fatalf("Eviction invalid: %#v", evicted)
+	}
+
+	if !evicted[0].LastSeen.Equal(start) {
+		t.Errorf("LastSeen invalid")
+	}
+
+	buffer.Advance(start.Add(2 * time.Second))
+
+	if len(evicted) != 2 || evicted[1].SequenceNumber != 2 {
+		t.Errorf("Eviction invalid: %#v", evicted)
+	}
+}
+
+func TestFragmentBuffer_OfferCommand(t *testing.T) {
+	fragment := func(sequenceNumber int32, number int32, flags uint8, data byte) PhotonCommand {
+		return PhotonCommand{
+			Type:                   SendReliableFragmentType,
+			ChannelID:              2,
+			Flags:                  flags,
+			ReservedByte:           4,
+			ReliableSequenceNumber: sequenceNumber,
+			Data: []byte{
+				0x00, 0x00, 0x00, 0x0a, // SequenceNumber
+				0x00, 0x00, 0x00, 0x02, // FragmentCount
+				0x00, 0x00, 0x00, byte(number), // FragmentNumber
+				0x00, 0x00, 0x00, 0x02, // TotalLength
+				0x00, 0x00, 0x00, byte(number), // FragmentOffset
+				data,
+			},
+		}
+	}
+
+	buffer := NewFragmentBuffer()
+
+	if response, err := buffer.OfferCommand(ConnectionKey{}, fragment(11, 1, 0, 0xfe)); response != nil || err != nil {
+		t.Fail()
+	}
+
+	response, err := buffer.OfferCommand(ConnectionKey{}, fragment(10, 0, 1, 0xca))
+
+	if response == nil || err != nil {
+		t.FailNow()
+	}
+
+	expected := PhotonCommand{
+		Type:                   SendReliableType,
+		ChannelID:              2,
+		Flags:                  1,
+		ReservedByte:           4,
+		Length:                 PhotonCommandHeaderLength + 2,
+		ReliableSequenceNumber: 10,
+		Data:                   []byte{0xca, 0xfe},
+	}
+
+	if !reflect.DeepEqual(expected, *response) {
+		t.Errorf("Expected %#v but got %#v", expected, *response)
+	}
+
+	if _, err := buffer.OfferCommand(ConnectionKey{}, PhotonCommand{Type: SendReliableType}); err == nil {
+		t.Errorf("Expected non fragment commands to be rejected")
+	}
+}
+
+func TestFragmentBuffer_Remove(t *testing.T) {
+	evicted := 0
+
+	buffer := NewFragmentBufferWithOptions(FragmentBufferOptions{
+		OnEvict: func(msg EvictedMessage) { evicted++ },
+	})
+
+	fragment := ReliableFragment{SequenceNumber: 1, FragmentCount: 2, TotalLength: 2, Data: []byte{0x01}}
+
+	buffer.OfferFrom(ConnectionKey{PeerID: 1}, 0, fragment)
+	buffer.OfferFrom(ConnectionKey{PeerID: 2}, 0, fragment)
+	buffer.Remove(ConnectionKey{PeerID: 1})
+
+	fragment.FragmentNumber = 1
+	fragment.FragmentOffset = 1
+
+	if response, _ := buffer.OfferFrom(ConnectionKey{PeerID: 1}, 0, fragment); response != nil {
+		t.Errorf("Expected the connection's fragments to be removed")
+	}
+
+	if response, _ := buffer.OfferFrom(ConnectionKey{PeerID: 2}, 0, fragment); response == nil {
+		t.Errorf("Expected other connections' fragments to remain")
+	}
+
+	if evicted != 0 {
+		t.Errorf("Expected removed messages not to be reported")
+	}
+}
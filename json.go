@@ -0,0 +1,266 @@
+package photon_spectator
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// A decoded value along with the name of its Photon type, which marshals to
+// JSON as {"t":"int16","v":5}. Typed slices are named after their element
+// type, such as "int16[]", and hold untagged elements; hashtables and object
+// slices tag each of their elements.
+type TypedValue struct {
+	Type  string      `json:"t"`
+	Value interface{} `json:"v"`
+}
+
+// An entry of a hashtable or dictionary, whose keys aren't limited to strings.
+type TypedEntry struct {
+	Key   TypedValue `json:"k"`
+	Value TypedValue `json:"v"`
+}
+
+type typedEventData struct {
+	Code       uint8                 `json:"code"`
+	Paramaters map[string]TypedValue `json:"params"`
+}
+
+type typedOperationResponse struct {
+	Code         uint8                 `json:"code"`
+	ReturnCode   int16                 `json:"returnCode"`
+	DebugMessage TypedValue            `json:"debugMessage"`
+	Paramaters   map[string]TypedValue `json:"params"`
+}
+
+type typedCustomType struct {
+	Code uint8  `json:"code"`
+	Data []byte `json:"data"`
+}
+
+// Returns a value tagged with its Photon type. Values of unrecognized types,
+// such as those returned by custom type decoders, are tagged "unknown".
+func NewTypedValue(value interface{}) TypedValue {
+	name := TypeName(value)
+
+	switch v := value.(type) {
+	case nil:
+		return TypedValue{name, nil}
+	case EventDataValue:
+		return TypedValue{name, typedEventData{v.Code, NewTypedParamaters(v.Paramaters)}}
+	case OperationRequestValue:
+		return TypedValue{name, typedEventData{v.Code, NewTypedParamaters(v.Paramaters)}}
+	case OperationResponseValue:
+		return TypedValue{name, typedOperationResponse{v.Code, v.ReturnCode, NewTypedValue(v.DebugMessage), NewTypedParamaters(v.Paramaters)}}
+	case CustomTypeValue:
+		return TypedValue{name, typedCustomType{v.Code, v.Data}}
+	}
+
+	if name == "unknown" {
+		return TypedValue{name, value}
+	}
+
+	return TypedValue{name, untypedValue(reflect.ValueOf(value))}
+}
+
+// Returns the name of the Photon type of a decoded value, as used by
+// TypedValue.
+func TypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case EventDataValue:
+		return "event"
+	case OperationRequestValue:
+		return "request"
+	case OperationResponseValue:
+		return "response"
+	case CustomTypeValue:
+		return "custom"
+	}
+
+	if name, ok := typeName(reflect.TypeOf(value)); ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// Returns the paramaters of a message with each value tagged with its type.
+func NewTypedParamaters(params ReliableMessageParamaters) map[string]TypedValue {
+	typed := make(map[string]TypedValue, len(params))
+
+	for key, value := range params {
+		typed[key] = NewTypedValue(value)
+	}
+
+	return typed
+}
+
+// Returns the name of the Photon type values of a Go type decode from.
+func typeName(t reflect.Type) (string, bool) {
+	switch t.Kind() {
+	case reflect.Uint8:
+		return "byte", true
+	case reflect.Int8:
+		return "int8", true
+	case reflect.Bool:
+		return "bool", true
+	case reflect.Int16:
+		return "int16", true
+	case reflect.Int32:
+		return "int32", true
+	case reflect.Int64:
+		return "int64", true
+	case reflect.Float32:
+		return "float32", true
+	case reflect.Float64:
+		return "double", true
+	case reflect.String:
+		return "string", true
+	case reflect.Interface:
+		return "object", true
+	case reflect.Map:
+		return "hashtable", true
+	case reflect.Slice:
+		name, ok := typeName(t.Elem())
+		return name + "[]", ok
+	default:
+		return "", false
+	}
+}
+
+// Returns the JSON representation of a value, tagging only the elements whose
+// types aren't implied by the type of the value.
+func untypedValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Interface:
+		return NewTypedValue(v.Interface())
+	case reflect.Float32, reflect.Float64:
+		return floatValue(v)
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		entries := make(map[string]TypedEntry, v.Len())
+
+		for _, key := range v.MapKeys() {
+			entry := TypedEntry{NewTypedValue(key.Interface()), NewTypedValue(v.MapIndex(key).Interface())}
+			encoded, _ := json.Marshal(entry.Key)
+
+			keys = append(keys, string(encoded))
+			entries[string(encoded)] = entry
+		}
+
+		// Map iteration is random, so order entries by their keys for stable output
+		sort.Strings(keys)
+
+		ordered := make([]TypedEntry, len(keys))
+
+		for i, key := range keys {
+			ordered[i] = entries[key]
+		}
+
+		return ordered
+	case reflect.Slice:
+		elements := make([]interface{}, v.Len())
+
+		for i := range elements {
+			elements[i] = untypedValue(v.Index(i))
+		}
+
+		return elements
+	default:
+		return v.Interface()
+	}
+}
+
+// Returns a float, or its name when JSON can't represent it.
+func floatValue(v reflect.Value) interface{} {
+	f := v.Float()
+
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return v.Interface()
+	}
+}
+
+type jsonMessage struct {
+	Time            string                `json:"time"`
+	NetworkSource   string                `json:"networkSrc"`
+	NetworkDest     string                `json:"networkDst"`
+	TransportSource string                `json:"transportSrc"`
+	TransportDest   string                `json:"transportDst"`
+	PeerID          uint16                `json:"peerId"`
+	Direction       string                `json:"direction"`
+	ChannelID       uint8                 `json:"channel"`
+	CommandType     uint8                 `json:"commandType"`
+	Unreliable      bool                  `json:"unreliable"`
+	MessageType     uint8                 `json:"messageType"`
+	OperationCode   *uint8                `json:"operationCode,omitempty"`
+	EventCode       *uint8                `json:"eventCode,omitempty"`
+	ReturnCode      *uint16               `json:"returnCode,omitempty"`
+	DebugMessage    *TypedValue           `json:"debugMessage,omitempty"`
+	Paramaters      map[string]TypedValue `json:"params"`
+}
+
+// Marshals the message with its paramaters tagged with their Photon types.
+func (m Message) MarshalJSON() ([]byte, error) {
+	header := m.Header
+	networkSrc, networkDst := m.Connection.Network.Endpoints()
+	transportSrc, transportDst := m.Connection.Transport.Endpoints()
+
+	msg := jsonMessage{
+		Time:            m.Time.UTC().Format(time.RFC3339Nano),
+		NetworkSource:   networkSrc.String(),
+		NetworkDest:     networkDst.String(),
+		TransportSource: transportSrc.String(),
+		TransportDest:   transportDst.String(),
+		PeerID:          m.Connection.PeerID,
+		Direction:       m.Direction.String(),
+		ChannelID:       m.ChannelID,
+		CommandType:     m.CommandType,
+		Unreliable:      header.Unreliable,
+		MessageType:     header.Type,
+		Paramaters:      NewTypedParamaters(m.Paramaters),
+	}
+
+	switch header.Type {
+	case EventDataType:
+		msg.EventCode = &header.EventCode
+	case OperationResponse:
+		msg.OperationCode = &header.OperationCode
+		msg.ReturnCode = &header.OperationResponseCode
+
+		if header.OperationDebugMessage != nil {
+			debugMessage := NewTypedValue(header.OperationDebugMessage)
+			msg.DebugMessage = &debugMessage
+		}
+	default:
+		msg.OperationCode = &header.OperationCode
+	}
+
+	return json.Marshal(msg)
+}
+
+// Writes messages as JSON Lines, one JSON object per line.
+type JSONLWriter struct {
+	encoder *json.Encoder
+}
+
+// Makes a new instance of a JSONLWriter
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	return &JSONLWriter{encoder: json.NewEncoder(w)}
+}
+
+// Writes a message followed by a newline.
+func (w *JSONLWriter) Write(msg Message) error {
+	return w.encoder.Encode(msg)
+}
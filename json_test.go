@@ -0,0 +1,85 @@
+package photon_spectator
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTypedValue(t *testing.T) {
+	values := []struct {
+		input  interface{}
+		output string
+	}{
+		{nil, `{"t":"nil","v":null}`},
+		{uint8(5), `{"t":"byte","v":5}`},
+		{int16(5), `{"t":"int16","v":5}`},
+		{int32(-5), `{"t":"int32","v":-5}`},
+		{int64(5), `{"t":"int64","v":5}`},
+		{float32(0.1), `{"t":"float32","v":0.1}`},
+		{math.Inf(-1), `{"t":"double","v":"-Inf"}`},
+		{"str", `{"t":"string","v":"str"}`},
+		{true, `{"t":"bool","v":true}`},
+		{[]uint8{1, 2}, `{"t":"byte[]","v":[1,2]}`},
+		{[]int8{-1, 2}, `{"t":"int8[]","v":[-1,2]}`},
+		{[][]int8{{1}, {}}, `{"t":"int8[][]","v":[[1],[]]}`},
+		{[]interface{}{int16(1), "a"}, `{"t":"object[]","v":[{"t":"int16","v":1},{"t":"string","v":"a"}]}`},
+		{map[interface{}]interface{}{uint8(2): "b", "a": int32(1)}, `{"t":"hashtable","v":[{"k":{"t":"byte","v":2},"v":{"t":"string","v":"b"}},{"k":{"t":"string","v":"a"},"v":{"t":"int32","v":1}}]}`},
+		{CustomTypeValue{Code: 1, Data: []byte{0xff}}, `{"t":"custom","v":{"code":1,"data":"/w=="}}`},
+		{EventDataValue{Code: 3, Paramaters: ReliableMessageParamaters{"1": int16(2)}}, `{"t":"event","v":{"code":3,"params":{"1":{"t":"int16","v":2}}}}`},
+		{struct{}{}, `{"t":"unknown","v":{}}`},
+	}
+
+	for _, v := range values {
+		actual, err := json.Marshal(NewTypedValue(v.input))
+
+		if err != nil {
+			t.Errorf("%s", err.Error())
+		}
+
+		if string(actual) != v.output {
+			t.Errorf("Expected %s but got %s", v.output, actual)
+		}
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	writer := NewJSONLWriter(buf)
+
+	msg := Message{
+		Connection: clientConnection,
+		Direction:  DirectionToServer,
+		Time:       time.Unix(1, 0),
+		Header:     ReliableMessage{Type: EventDataType, EventCode: 4},
+		Paramaters: ReliableMessageParamaters{"1": int16(5)},
+	}
+
+	writer.Write(msg)
+	writer.Write(msg)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines but got %d", len(lines))
+	}
+
+	expected := `{"time":"1970-01-01T00:00:01Z","networkSrc":"10.0.0.1","networkDst":"10.0.0.2","transportSrc":"50000","transportDst":"5056","peerId":0,"direction":"ToServer","channel":0,"commandType":0,"unreliable":false,"messageType":4,"eventCode":4,"params":{"1":{"t":"int16","v":5}}}`
+
+	if lines[0] != expected {
+		t.Errorf("Expected %s but got %s", expected, lines[0])
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	values := []interface{}{nil, int16(1), []interface{}{}, map[interface{}]interface{}{}, OperationResponseValue{}, struct{}{}, [][]int8{}}
+
+	for _, value := range values {
+		if actual, expected := TypeName(value), NewTypedValue(value).Type; actual != expected {
+			t.Errorf("Expected %s but got %s", expected, actual)
+		}
+	}
+}
@@ -16,11 +16,18 @@ const (
 	SendReliableType         = 6
 	SendUnreliableType       = 7
 	SendReliableFragmentType = 8
+	SendUnsequencedType      = 11
+	ServerTimeType           = 12
+	// Command flags
+	ReliableFlag = 1
 	// Message types
 	OperationRequest       = 2
 	otherOperationResponse = 3
 	EventDataType          = 4
 	OperationResponse      = 7
+	// Serialization protocols
+	Protocol16 = 16
+	Protocol18 = 18
 )
 
 type PhotonCommand struct {
@@ -36,10 +43,22 @@ type PhotonCommand struct {
 	Data []byte
 }
 
+// Reports whether the command is sent reliably, and so is retransmitted until
+// acknowledged and delivered in order of its ReliableSequenceNumber.
+func (c PhotonCommand) IsReliable() bool {
+	return c.Flags&ReliableFlag != 0
+}
+
 type ReliableMessage struct {
 	// Header
 	Signature uint8
 	Type      uint8
+	Protocol  uint8
+
+	// Set when the message was sent by a SendUnreliableType command, and so may
+	// be lost or arrive out of order
+	Unreliable               bool
+	UnreliableSequenceNumber int32
 
 	// OperationRequest
 	OperationCode uint8
@@ -50,6 +69,7 @@ type ReliableMessage struct {
 	// OperationResponse
 	OperationResponseCode uint16
 	OperationDebugByte    uint8
+	OperationDebugMessage interface{}
 
 	ParamaterCount int16
 	Data           []byte
@@ -65,15 +85,81 @@ type ReliableFragment struct {
 	Data []byte
 }
 
+type Acknowledgement struct {
+	ReceivedSequenceNumber int32
+	// Timestamp of the packet which carried the acknowledged command, in the
+	// clock of its sender
+	ReceivedSentTime uint32
+}
+
+// Photon pings carry no fields of their own; they are answered by an
+// acknowledgement like any other reliable command.
+type Ping struct {
+	Data []byte
+}
+
+// The layout of the connect payload varies between Photon versions, so it is
+// left undecoded.
+type Connect struct {
+	Data []byte
+}
+
+type VerifyConnect struct {
+	// Assigned to the client by the server
+	PeerID uint16
+
+	Data []byte
+}
+
+type Disconnect struct {
+	Data []byte
+}
+
+type Unreliable struct {
+	UnreliableSequenceNumber int32
+
+	Data []byte
+}
+
+type Unsequenced struct {
+	UnsequencedGroupNumber int32
+
+	Data []byte
+}
+
+// Requests the server's time, which is returned as the ReceivedSentTime of
+// the acknowledgement answering it.
+type ServerTime struct {
+	Data []byte
+}
+
 
 // Returns a structure containing the fields of a reliable message.
-// Errors if the type is not SendReliableType.
+// Errors if the type is not SendReliableType or SendUnreliableType.
 func (c PhotonCommand) ReliableMessage() (msg ReliableMessage, err error) {
-	if c.Type != SendReliableType {
+	return c.ReliableMessageWithProtocol(Protocol16)
+}
+
+// Returns a structure containing the fields of a reliable message serialized
+// with the given protocol, either Protocol16 or Protocol18. Messages of
+// unreliable commands are read after their unreliable sequence number.
+// Errors if the type is not SendReliableType or SendUnreliableType.
+func (c PhotonCommand) ReliableMessageWithProtocol(protocol uint8) (msg ReliableMessage, err error) {
+	if c.Type != SendReliableType && c.Type != SendUnreliableType {
 		return msg, fmt.Errorf("Command can't be converted")
 	}
 
+	if protocol != Protocol16 && protocol != Protocol18 {
+		return msg, fmt.Errorf("Invalid protocol of %d", protocol)
+	}
+
 	buf := bytes.NewBuffer(c.Data)
+	msg.Protocol = protocol
+
+	if c.Type == SendUnreliableType {
+		msg.Unreliable = true
+		binary.Read(buf, binary.BigEndian, &msg.UnreliableSequenceNumber)
+	}
 
 	binary.Read(buf, binary.BigEndian, &msg.Signature)
 	binary.Read(buf, binary.BigEndian, &msg.Type)
@@ -82,6 +168,12 @@ func (c PhotonCommand) ReliableMessage() (msg ReliableMessage, err error) {
 		msg.Type = OperationResponse
 	}
 
+	if protocol == Protocol18 {
+		err = readProtocol18MessageHeader(buf, &msg)
+		msg.Data = buf.Bytes()
+		return
+	}
+
 	switch msg.Type {
 	case OperationRequest:
 		binary.Read(buf, binary.BigEndian, &msg.OperationCode)
@@ -118,3 +210,144 @@ func (c PhotonCommand) ReliableFragment() (msg ReliableFragment, err error) {
 
 	return
 }
+
+// Returns a structure containing the fields of an acknowledgement.
+// Errors if the type is not AcknowledgeType.
+func (c PhotonCommand) Acknowledgement() (msg Acknowledgement, err error) {
+	if c.Type != AcknowledgeType {
+		return msg, fmt.Errorf("Command can't be converted")
+	}
+
+	buf := bytes.NewBuffer(c.Data)
+
+	binary.Read(buf, binary.BigEndian, &msg.ReceivedSequenceNumber)
+	binary.Read(buf, binary.BigEndian, &msg.ReceivedSentTime)
+
+	return
+}
+
+// Returns a structure containing the fields of a ping.
+// Errors if the type is not PingType.
+func (c PhotonCommand) Ping() (msg Ping, err error) {
+	if c.Type != PingType {
+		return msg, fmt.Errorf("Command can't be converted")
+	}
+
+	msg.Data = c.Data
+
+	return
+}
+
+// Returns a structure containing the fields of a connect.
+// Errors if the type is not ConnectType.
+func (c PhotonCommand) Connect() (msg Connect, err error) {
+	if c.Type != ConnectType {
+		return msg, fmt.Errorf("Command can't be converted")
+	}
+
+	msg.Data = c.Data
+
+	return
+}
+
+// Returns a structure containing the fields of a verify connect.
+// Errors if the type is not VerifyConnectType.
+func (c PhotonCommand) VerifyConnect() (msg VerifyConnect, err error) {
+	if c.Type != VerifyConnectType {
+		return msg, fmt.Errorf("Command can't be converted")
+	}
+
+	buf := bytes.NewBuffer(c.Data)
+
+	binary.Read(buf, binary.BigEndian, &msg.PeerID)
+
+	msg.Data = buf.Bytes()
+
+	return
+}
+
+// Returns a structure containing the fields of a disconnect.
+// Errors if the type is not DisconnectType.
+func (c PhotonCommand) Disconnect() (msg Disconnect, err error) {
+	if c.Type != DisconnectType {
+		return msg, fmt.Errorf("Command can't be converted")
+	}
+
+	msg.Data = c.Data
+
+	return
+}
+
+// Returns a structure containing the fields of an unreliable send.
+// Errors if the type is not SendUnreliableType.
+func (c PhotonCommand) Unreliable() (msg Unreliable, err error) {
+	if c.Type != SendUnreliableType {
+		return msg, fmt.Errorf("Command can't be converted")
+	}
+
+	buf := bytes.NewBuffer(c.Data)
+
+	binary.Read(buf, binary.BigEndian, &msg.UnreliableSequenceNumber)
+
+	msg.Data = buf.Bytes()
+
+	return
+}
+
+// Returns a structure containing the fields of an unsequenced send.
+// Errors if the type is not SendUnsequencedType.
+func (c PhotonCommand) Unsequenced() (msg Unsequenced, err error) {
+	if c.Type != SendUnsequencedType {
+		return msg, fmt.Errorf("Command can't be converted")
+	}
+
+	buf := bytes.NewBuffer(c.Data)
+
+	binary.Read(buf, binary.BigEndian, &msg.UnsequencedGroupNumber)
+
+	msg.Data = buf.Bytes()
+
+	return
+}
+
+// Returns a structure containing the fields of a server time request.
+// Errors if the type is not ServerTimeType.
+func (c PhotonCommand) ServerTime() (msg ServerTime, err error) {
+	if c.Type != ServerTimeType {
+		return msg, fmt.Errorf("Command can't be converted")
+	}
+
+	msg.Data = c.Data
+
+	return
+}
+
+// Returns the structure for the command's type, such as a ReliableFragment
+// for SendReliableFragmentType. Reliable messages are read with Protocol16.
+// Errors if the type is unknown.
+func (c PhotonCommand) Body() (interface{}, error) {
+	switch c.Type {
+	case AcknowledgeType:
+		return c.Acknowledgement()
+	case ConnectType:
+		return c.Connect()
+	case VerifyConnectType:
+		return c.VerifyConnect()
+	case DisconnectType:
+		return c.Disconnect()
+	case PingType:
+		return c.Ping()
+	case SendReliableType:
+		return c.ReliableMessage()
+	case SendUnreliableType:
+		return c.Unreliable()
+	case SendReliableFragmentType:
+		return c.ReliableFragment()
+	case SendUnsequencedType:
+		return c.Unsequenced()
+	case ServerTimeType:
+		return c.ServerTime()
+	default:
+		return nil, fmt.Errorf("Invalid command type of %d", c.Type)
+	}
+}
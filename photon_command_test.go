@@ -62,6 +62,49 @@ func TestPhotonCommand_ReliableMessage_EventData(t *testing.T) {
 	}
 }
 
+func TestPhotonCommand_ReliableMessage_Unreliable(t *testing.T) {
+	var cmd PhotonCommand
+	cmd.Type = SendUnreliableType
+	cmd.Data = []byte{0x00, 0x00, 0x00, 0x09, 0xf3, EventDataType, 0x01, 0x00, 0x01, 0x02}
+
+	msg, err := cmd.ReliableMessage()
+
+	if err != nil {
+		t.Errorf("%s", err.Error())
+	}
+
+	if !msg.Unreliable {
+		t.Errorf("Unreliable invalid")
+	}
+
+	if msg.UnreliableSequenceNumber != 9 {
+		t.Errorf("UnreliableSequenceNumber invalid")
+	}
+
+	if msg.Type != EventDataType {
+		t.Errorf("Type invalid")
+	}
+
+	if msg.EventCode != uint8(1) {
+		t.Errorf("EventCode invalid")
+	}
+
+	if msg.ParamaterCount != int16(1) {
+		t.Errorf("ParamaterCount invalid")
+	}
+
+	if !reflect.DeepEqual(msg.Data, []byte{0x02}) {
+		t.Errorf("Data invalid")
+	}
+
+	cmd.Type = SendReliableType
+	msg, _ = cmd.ReliableMessage()
+
+	if msg.Unreliable {
+		t.Errorf("Reliable message marked unreliable")
+	}
+}
+
 func TestPhotonCommand_ReliableMessage_OperationResponse(t *testing.T) {
 	var cmd PhotonCommand
 	cmd.Type = SendReliableType
@@ -126,3 +169,176 @@ func TestPhotonCommand_ReliableFragment(t *testing.T) {
 	}
 
 }
+
+func TestPhotonCommand_ReliableMessageWithProtocol_InvalidProtocol(t *testing.T) {
+	var cmd PhotonCommand
+	cmd.Type = SendReliableType
+
+	_, err := cmd.ReliableMessageWithProtocol(17)
+
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestPhotonCommand_ReliableMessageWithProtocol_Protocol18(t *testing.T) {
+	var cmd PhotonCommand
+	cmd.Type = SendReliableType
+	cmd.Data = []byte{0xf3, otherOperationResponse, 0x01, 0x02, 0x00, Protocol18StringType, 0x01, 0x61, 0x01, 0x00, Protocol18ByteType, 0x03}
+
+	msg, err := cmd.ReliableMessageWithProtocol(Protocol18)
+
+	if err != nil {
+		t.Errorf("%s", err.Error())
+	}
+
+	if msg.Protocol != Protocol18 {
+		t.Errorf("Protocol invalid")
+	}
+
+	if msg.Type != OperationResponse {
+		t.Errorf("Type invalid")
+	}
+
+	if msg.OperationResponseCode != uint16(2) {
+		t.Errorf("OperationResponseCode invalid")
+	}
+
+	if msg.OperationDebugMessage != "a" {
+		t.Errorf("OperationDebugMessage invalid")
+	}
+
+	if msg.ParamaterCount != int16(1) {
+		t.Errorf("ParamaterCount invalid")
+	}
+
+	params, err := DecodeReliableMessage(msg)
+
+	if err != nil {
+		t.Errorf("%s", err.Error())
+	}
+
+	if !reflect.DeepEqual(params, ReliableMessageParamaters{"0": uint8(3)}) {
+		t.Errorf("Paramaters invalid")
+	}
+}
+
+func TestPhotonCommand_IsReliable(t *testing.T) {
+	if !(PhotonCommand{Flags: ReliableFlag}).IsReliable() {
+		t.Errorf("Expected command to be reliable")
+	}
+
+	if (PhotonCommand{}).IsReliable() {
+		t.Errorf("Expected command to be unreliable")
+	}
+}
+
+func TestPhotonCommand_Acknowledgement(t *testing.T) {
+	expected := Acknowledgement{ReceivedSequenceNumber: 2, ReceivedSentTime: 0x100}
+
+	var cmd PhotonCommand
+	cmd.Type = AcknowledgeType
+	cmd.Data = []byte{
+		0x0, 0x0, 0x0, 0x2, // ReceivedSequenceNumber
+		0x0, 0x0, 0x1, 0x0, // ReceivedSentTime
+	}
+
+	ack, err := cmd.Acknowledgement()
+
+	if err != nil {
+		t.Errorf("%s", err.Error())
+	}
+
+	if !reflect.DeepEqual(expected, ack) {
+		t.Errorf("Expected %#v but got %#v", expected, ack)
+	}
+
+	cmd.Type = PingType
+
+	if _, err := cmd.Acknowledgement(); err == nil {
+		t.Fail()
+	}
+}
+
+func TestPhotonCommand_Ping(t *testing.T) {
+	var cmd PhotonCommand
+
+	if _, err := cmd.Ping(); err == nil {
+		t.Fail()
+	}
+
+	cmd.Type = PingType
+
+	if _, err := cmd.Ping(); err != nil {
+		t.Errorf("%s", err.Error())
+	}
+}
+
+func TestPhotonCommand_Body(t *testing.T) {
+	data := []byte{0x0, 0x0, 0x0, 0x7, 0xca, 0xfe}
+
+	bodies := []struct {
+		commandType uint8
+		expected    interface{}
+	}{
+		{ConnectType, Connect{Data: data}},
+		{VerifyConnectType, VerifyConnect{PeerID: 0, Data: []byte{0x0, 0x7, 0xca, 0xfe}}},
+		{DisconnectType, Disconnect{Data: data}},
+		{PingType, Ping{Data: data}},
+		{SendUnreliableType, Unreliable{UnreliableSequenceNumber: 7, Data: []byte{0xca, 0xfe}}},
+		{SendUnsequencedType, Unsequenced{UnsequencedGroupNumber: 7, Data: []byte{0xca, 0xfe}}},
+		{ServerTimeType, ServerTime{Data: data}},
+		{AcknowledgeType, Acknowledgement{ReceivedSequenceNumber: 7, ReceivedSentTime: 0}},
+	}
+
+	for _, b := range bodies {
+		var cmd PhotonCommand
+		cmd.Type = b.commandType
+		cmd.Data = data
+
+		actual, err := cmd.Body()
+
+		if err != nil {
+			t.Errorf("%s", err.Error())
+		}
+
+		if !reflect.DeepEqual(b.expected, actual) {
+			t.Errorf("Expected %#v but got %#v", b.expected, actual)
+		}
+	}
+
+	var cmd PhotonCommand
+	cmd.Type = 0xff
+
+	if _, err := cmd.Body(); err == nil {
+		t.Fail()
+	}
+}
+
+func TestPhotonCommand_Accessors_InvalidType(t *testing.T) {
+	var cmd PhotonCommand
+
+	if _, err := cmd.Connect(); err == nil {
+		t.Errorf("Connect should error")
+	}
+
+	if _, err := cmd.VerifyConnect(); err == nil {
+		t.Errorf("VerifyConnect should error")
+	}
+
+	if _, err := cmd.Disconnect(); err == nil {
+		t.Errorf("Disconnect should error")
+	}
+
+	if _, err := cmd.Unreliable(); err == nil {
+		t.Errorf("Unreliable should error")
+	}
+
+	if _, err := cmd.Unsequenced(); err == nil {
+		t.Errorf("Unsequenced should error")
+	}
+
+	if _, err := cmd.ServerTime(); err == nil {
+		t.Errorf("ServerTime should error")
+	}
+}
@@ -1,21 +1,39 @@
 package photon_spectator
 
 import (
-	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
 
 	"github.com/google/gopacket"
 )
 
 const (
+	PhotonHeaderLength        = 12
 	PhotonCommandHeaderLength = 12
 )
 
+// Errors returned when decoding a malformed packet. gopacket records them in a
+// DecodeFailure layer, available through the packet's ErrorLayer.
+var (
+	ErrTruncatedHeader        = errors.New("Photon header is truncated")
+	ErrTruncatedCommandHeader = errors.New("Photon command header is truncated")
+	ErrCommandLengthNegative  = errors.New("Photon command length is shorter than its header")
+	ErrCommandLengthOverflow  = errors.New("Photon command length overruns the packet")
+)
+
 var PhotonLayerType = gopacket.RegisterLayerType(5056,
 	gopacket.LayerTypeMetadata{
 		Name:    "PhotonLayerType",
 		Decoder: gopacket.DecodeFunc(decodePhotonPacket)})
 
+// Decodes a PhotonLayer as PhotonLayerType does, except that packets failing
+// CRC validation fail to decode with ErrCrcMismatch.
+var PhotonLayerTypeDropInvalidCrc = gopacket.RegisterLayerType(5057,
+	gopacket.LayerTypeMetadata{
+		Name:    "PhotonLayerTypeDropInvalidCrc",
+		Decoder: gopacket.DecodeFunc(decodePhotonPacketDropInvalidCrc)})
+
 type PhotonLayer struct {
 	// Header
 	PeerID       uint16
@@ -24,6 +42,15 @@ type PhotonLayer struct {
 	Timestamp    uint32
 	Challenge    int32
 
+	// Checksum, present when CrcEnabled is PhotonCrcEnabled. CrcValid is only
+	// false when a present checksum doesn't match the packet.
+	Crc      uint32
+	CrcValid bool
+
+	// When set, DecodeFromBytes fails packets failing CRC validation with
+	// ErrCrcMismatch. Otherwise they decode with CrcValid set to false.
+	DropInvalidCrc bool
+
 	// Commands
 	Commands []PhotonCommand
 
@@ -36,52 +63,175 @@ func (p PhotonLayer) LayerType() gopacket.LayerType { return PhotonLayerType }
 func (p PhotonLayer) LayerContents() []byte         { return p.contents }
 func (p PhotonLayer) LayerPayload() []byte          { return p.payload }
 
-func decodePhotonPacket(data []byte, p gopacket.PacketBuilder) error {
-	layer := PhotonLayer{}
-	buf := bytes.NewBuffer(data)
+// Writes the header and commands of the layer in front of the buffer's current
+// contents. With FixLengths set, CommandCount and each command's Length are
+// computed from the commands rather than taken from the layer. With
+// ComputeChecksums set, the checksum of a packet with CrcEnabled is computed
+// over the whole packet rather than taken from the layer.
+func (p PhotonLayer) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	size := p.headerLength()
+
+	for _, command := range p.Commands {
+		size += PhotonCommandHeaderLength + len(command.Data)
+	}
+
+	data, err := b.PrependBytes(size)
+
+	if err != nil {
+		return err
+	}
+
+	commandCount := p.CommandCount
+
+	if opts.FixLengths {
+		if len(p.Commands) > 0xff {
+			return fmt.Errorf("Too many commands to serialize: %d", len(p.Commands))
+		}
+
+		commandCount = uint8(len(p.Commands))
+	}
+
+	binary.BigEndian.PutUint16(data[0:], p.PeerID)
+	data[2] = p.CrcEnabled
+	data[3] = commandCount
+	binary.BigEndian.PutUint32(data[4:], p.Timestamp)
+	binary.BigEndian.PutUint32(data[8:], uint32(p.Challenge))
+
+	offset := p.headerLength()
+
+	for _, command := range p.Commands {
+		length := command.Length
+
+		if opts.FixLengths {
+			length = int32(PhotonCommandHeaderLength + len(command.Data))
+		}
+
+		data[offset] = command.Type
+		data[offset+1] = command.ChannelID
+		data[offset+2] = command.Flags
+		data[offset+3] = command.ReservedByte
+		binary.BigEndian.PutUint32(data[offset+4:], uint32(length))
+		binary.BigEndian.PutUint32(data[offset+8:], uint32(command.ReliableSequenceNumber))
+		copy(data[offset+PhotonCommandHeaderLength:], command.Data)
+
+		offset += PhotonCommandHeaderLength + len(command.Data)
+	}
+
+	if p.CrcEnabled == PhotonCrcEnabled {
+		crc := p.Crc
+
+		if opts.ComputeChecksums {
+			crc = photonCrc(b.Bytes())
+		}
+
+		binary.BigEndian.PutUint32(data[PhotonHeaderLength:], crc)
+	}
+
+	return nil
+}
+
+func (p PhotonLayer) headerLength() int {
+	if p.CrcEnabled == PhotonCrcEnabled {
+		return PhotonHeaderLength + PhotonCrcLength
+	}
+
+	return PhotonHeaderLength
+}
+
+// Decodes the header and commands of a packet into the layer, reusing its
+// command slice. Command data references the given bytes rather than copying.
+func (p *PhotonLayer) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < PhotonHeaderLength {
+		return ErrTruncatedHeader
+	}
 
 	// Read the header
-	binary.Read(buf, binary.BigEndian, &layer.PeerID)
-	binary.Read(buf, binary.BigEndian, &layer.CrcEnabled)
-	binary.Read(buf, binary.BigEndian, &layer.CommandCount)
-	binary.Read(buf, binary.BigEndian, &layer.Timestamp)
-	binary.Read(buf, binary.BigEndian, &layer.Challenge)
+	p.PeerID = binary.BigEndian.Uint16(data[0:])
+	p.CrcEnabled = data[2]
+	p.CommandCount = data[3]
+	p.Timestamp = binary.BigEndian.Uint32(data[4:])
+	p.Challenge = int32(binary.BigEndian.Uint32(data[8:]))
+
+	offset := p.headerLength()
 
-	var commands []PhotonCommand
+	if len(data) < offset {
+		return ErrTruncatedHeader
+	}
+
+	// Check the checksum
+	p.Crc = 0
+	p.CrcValid = true
+
+	if p.CrcEnabled == PhotonCrcEnabled {
+		p.Crc = binary.BigEndian.Uint32(data[PhotonHeaderLength:])
+		p.CrcValid = p.Crc == photonCrc(data)
+
+		if !p.CrcValid && p.DropInvalidCrc {
+			return ErrCrcMismatch
+		}
+	}
+
+	p.Commands = p.Commands[:0]
 
 	// Read each command
-	for i := 0; i < int(layer.CommandCount); i++ {
+	for i := 0; i < int(p.CommandCount); i++ {
 		var command PhotonCommand
 
+		if len(data)-offset < PhotonCommandHeaderLength {
+			return ErrTruncatedCommandHeader
+		}
+
 		// Command header
-		binary.Read(buf, binary.BigEndian, &command.Type)
-		binary.Read(buf, binary.BigEndian, &command.ChannelID)
-		binary.Read(buf, binary.BigEndian, &command.Flags)
-		binary.Read(buf, binary.BigEndian, &command.ReservedByte)
-		binary.Read(buf, binary.BigEndian, &command.Length)
-		binary.Read(buf, binary.BigEndian, &command.ReliableSequenceNumber)
+		command.Type = data[offset]
+		command.ChannelID = data[offset+1]
+		command.Flags = data[offset+2]
+		command.ReservedByte = data[offset+3]
+		command.Length = int32(binary.BigEndian.Uint32(data[offset+4:]))
+		command.ReliableSequenceNumber = int32(binary.BigEndian.Uint32(data[offset+8:]))
+		offset += PhotonCommandHeaderLength
 
 		// Command data
 		dataLength := int(command.Length) - PhotonCommandHeaderLength
 
+		if dataLength < 0 {
+			return ErrCommandLengthNegative
+		}
+
 		// Ensure we don't try to read more than we have
-		if dataLength > buf.Len() {
-			panic("Data is malformed")
+		if dataLength > len(data)-offset {
+			return ErrCommandLengthOverflow
 		}
 
-		command.Data = make([]byte, dataLength)
-		buf.Read(command.Data)
+		command.Data = data[offset : offset+dataLength]
+		offset += dataLength
 
-		commands = append(commands, command)
+		p.Commands = append(p.Commands, command)
 	}
 
-	layer.Commands = commands
-
 	// Split and store the read and unread data
-	dataUsed := len(data) - buf.Len()
-	layer.contents = data[0:dataUsed]
-	layer.payload = buf.Bytes()
+	p.contents = data[0:offset]
+	p.payload = data[offset:]
+
+	return nil
+}
+
+func (p *PhotonLayer) CanDecode() gopacket.LayerClass    { return PhotonLayerType }
+func (p *PhotonLayer) NextLayerType() gopacket.LayerType { return gopacket.LayerTypePayload }
+
+func decodePhotonPacket(data []byte, p gopacket.PacketBuilder) error {
+	return decodePhotonLayer(data, p, PhotonLayer{})
+}
+
+func decodePhotonPacketDropInvalidCrc(data []byte, p gopacket.PacketBuilder) error {
+	return decodePhotonLayer(data, p, PhotonLayer{DropInvalidCrc: true})
+}
+
+func decodePhotonLayer(data []byte, p gopacket.PacketBuilder, layer PhotonLayer) error {
+
+	if err := layer.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
 
 	p.AddLayer(layer)
-	return p.NextDecoder(gopacket.LayerTypePayload)
+	return p.NextDecoder(layer.NextLayerType())
 }
@@ -1,6 +1,7 @@
 package photon_spectator
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/google/gopacket"
@@ -118,3 +119,179 @@ func TestMalformedCommand(t *testing.T) {
 		t.Errorf("Photon layer should be absent")
 	}
 }
+
+func TestPhotonLayer_SerializeTo(t *testing.T) {
+	data := []byte{
+		0x00, 0x01, // PeerID
+		0x00,                   // CrcEnabled
+		0x02,                   // CommandCount
+		0x00, 0x00, 0x00, 0x01, // Timestamp
+		0x00, 0x00, 0x00, 0x01, // Challenge
+		AcknowledgeType, 0x01, 0x01, 0x04, 0x00, 0x00, 0x00, 0x0c, 0x00, 0x00, 0x00, 0x01,
+		SendReliableType, 0x00, 0x01, 0x04, 0x00, 0x00, 0x00, 0x0e, 0x00, 0x00, 0x00, 0x02, 0xca, 0xfe,
+		0xff, // Payload
+	}
+
+	packet := gopacket.NewPacket(data, PhotonLayerType, gopacket.Default)
+	layer := packet.Layer(PhotonLayerType).(PhotonLayer)
+
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, layer, gopacket.Payload(layer.LayerPayload()))
+
+	if err != nil {
+		t.Errorf("%s", err.Error())
+	}
+
+	if !reflect.DeepEqual(data, buf.Bytes()) {
+		t.Errorf("Expected %#v but got %#v", data, buf.Bytes())
+	}
+}
+
+func TestPhotonLayer_SerializeTo_FixLengths(t *testing.T) {
+	layer := PhotonLayer{
+		PeerID:   1,
+		Commands: []PhotonCommand{{Type: SendReliableType, Data: []byte{0xca, 0xfe}}},
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, layer)
+
+	if err != nil {
+		t.Errorf("%s", err.Error())
+	}
+
+	packet := gopacket.NewPacket(buf.Bytes(), PhotonLayerType, gopacket.Default)
+	decoded, _ := packet.Layer(PhotonLayerType).(PhotonLayer)
+
+	if decoded.CommandCount != 1 {
+		t.Errorf("CommandCount invalid")
+	}
+
+	if len(decoded.Commands) != 1 || decoded.Commands[0].Length != 14 {
+		t.Errorf("Length invalid")
+	}
+
+	if !reflect.DeepEqual(decoded.Commands[0].Data, []byte{0xca, 0xfe}) {
+		t.Errorf("Data invalid")
+	}
+}
+
+func TestMalformedPackets(t *testing.T) {
+	photonHeader := []byte{
+		0x00, 0x01, // PeerID
+		0x01,                   // CrcEnabled
+		0x01,                   // CommandCount
+		0x00, 0x00, 0x00, 0x01, // Timestamp
+		0x00, 0x00, 0x00, 0x01, // Challenge
+	}
+
+	commandHeader := func(length byte) []byte {
+		return []byte{AcknowledgeType, 0x01, 0x01, 0x04, 0x00, 0x00, 0x00, length, 0x00, 0x00, 0x00, 0x01}
+	}
+
+	malformed := []struct {
+		data []byte
+		err  error
+	}{
+		{photonHeader[:6], ErrTruncatedHeader},
+		{photonHeader, ErrTruncatedCommandHeader},
+		{append(append([]byte{}, photonHeader...), commandHeader(0x0d)...), ErrCommandLengthOverflow},
+		{append(append([]byte{}, photonHeader...), commandHeader(0x0b)...), ErrCommandLengthNegative},
+	}
+
+	for _, m := range malformed {
+		packet := gopacket.NewPacket(m.data, PhotonLayerType, gopacket.Default)
+
+		if packet.Layer(PhotonLayerType) != nil {
+			t.Errorf("Photon layer should be absent")
+		}
+
+		failure, ok := packet.ErrorLayer().(*gopacket.DecodeFailure)
+
+		if !ok {
+			t.Errorf("Decode failure should be present")
+			continue
+		}
+
+		if failure.Error() != m.err {
+			t.Errorf("Expected %v but got %v", m.err, failure.Error())
+		}
+	}
+}
+
+func TestPhotonLayer_DecodingLayerParser(t *testing.T) {
+	data := []byte{
+		0x00, 0x01, // PeerID
+		0x00,                   // CrcEnabled
+		0x01,                   // CommandCount
+		0x00, 0x00, 0x00, 0x01, // Timestamp
+		0x00, 0x00, 0x00, 0x01, // Challenge
+		SendReliableType, 0x00, 0x01, 0x04, 0x00, 0x00, 0x00, 0x0e, 0x00, 0x00, 0x00, 0x02, 0xca, 0xfe,
+	}
+
+	var layer PhotonLayer
+	var payload gopacket.Payload
+	var decoded []gopacket.LayerType
+
+	parser := gopacket.NewDecodingLayerParser(PhotonLayerType, &layer, &payload)
+
+	if err := parser.DecodeLayers(data, &decoded); err != nil {
+		t.Errorf("%s", err.Error())
+	}
+
+	if len(decoded) != 1 || decoded[0] != PhotonLayerType {
+		t.Errorf("Decoded layers invalid")
+	}
+
+	if layer.PeerID != 1 || len(layer.Commands) != 1 {
+		t.Errorf("Layer invalid")
+	}
+
+	if !reflect.DeepEqual(layer.Commands[0].Data, []byte{0xca, 0xfe}) {
+		t.Errorf("Data invalid")
+	}
+
+	if err := parser.DecodeLayers(data[:20], &decoded); err != ErrTruncatedCommandHeader {
+		t.Errorf("Expected %v but got %v", ErrTruncatedCommandHeader, err)
+	}
+}
+
+var benchmarkPacket = []byte{
+	0x00, 0x01, // PeerID
+	0x00,                   // CrcEnabled
+	0x03,                   // CommandCount
+	0x00, 0x00, 0x00, 0x01, // Timestamp
+	0x00, 0x00, 0x00, 0x01, // Challenge
+	AcknowledgeType, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x14, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+	SendReliableType, 0x00, 0x01, 0x04, 0x00, 0x00, 0x00, 0x15, 0x00, 0x00, 0x00, 0x02,
+	0xf3, EventDataType, 0x01, 0x00, 0x01, 0x00, ByteType, 0x01, 0x02,
+	SendUnreliableType, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x03,
+	0x00, 0x00, 0x00, 0x01,
+}
+
+func BenchmarkDecodePhotonPacket_NewPacket(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		packet := gopacket.NewPacket(benchmarkPacket, PhotonLayerType, gopacket.NoCopy)
+
+		if packet.Layer(PhotonLayerType) == nil {
+			b.Fatal("Photon layer should be present")
+		}
+	}
+}
+
+func BenchmarkDecodePhotonPacket_DecodingLayerParser(b *testing.B) {
+	var layer PhotonLayer
+	var payload gopacket.Payload
+	decoded := make([]gopacket.LayerType, 0, 2)
+
+	parser := gopacket.NewDecodingLayerParser(PhotonLayerType, &layer, &payload)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if err := parser.DecodeLayers(benchmarkPacket, &decoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package photon_spectator
+
+import (
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// UDP ports Photon servers listen on by default.
+var DefaultPorts = []uint16{5055, 5056, 4530, 4531}
+
+// Registers PhotonLayerType as the layer decoded from UDP payloads sent to or
+// from the given ports, or DefaultPorts when none are given. This is opt-in as
+// the registration is global to gopacket.
+func RegisterUDPPorts(ports ...uint16) {
+	RegisterUDPPortsWithLayerType(PhotonLayerType, ports...)
+}
+
+// Registers the given layer type, such as PhotonLayerTypeDropInvalidCrc, as
+// the layer decoded from UDP payloads sent to or from the given ports, or
+// DefaultPorts when none are given.
+func RegisterUDPPortsWithLayerType(layerType gopacket.LayerType, ports ...uint16) {
+	if len(ports) == 0 {
+		ports = DefaultPorts
+	}
+
+	for _, port := range ports {
+		layers.RegisterUDPPortLayerType(layers.UDPPort(port), layerType)
+	}
+}
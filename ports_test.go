@@ -0,0 +1,80 @@
+package photon_spectator
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func serializeUDPPacket(t *testing.T, photon PhotonLayer) []byte {
+
+	ethernet := layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+		DstMAC:       net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+
+	ip := layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.IP{10, 0, 0, 1},
+		DstIP:    net.IP{10, 0, 0, 2},
+	}
+
+	udp := layers.UDP{SrcPort: 50000, DstPort: 5056}
+	udp.SetNetworkLayerForChecksum(&ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+
+	if err := gopacket.SerializeLayers(buf, opts, &ethernet, &ip, &udp, photon); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	return buf.Bytes()
+}
+
+func TestRegisterUDPPorts(t *testing.T) {
+	RegisterUDPPorts()
+
+	data := serializeUDPPacket(t, PhotonLayer{
+		PeerID:   1,
+		Commands: []PhotonCommand{{Type: AcknowledgeType, Data: []byte{}}},
+	})
+
+	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+	layer, ok := packet.Layer(PhotonLayerType).(PhotonLayer)
+
+	if !ok {
+		t.Fatalf("Photon layer should be present")
+	}
+
+	if layer.PeerID != 1 || len(layer.Commands) != 1 {
+		t.Errorf("Photon layer invalid")
+	}
+}
+
+func TestRegisterUDPPortsWithLayerType(t *testing.T) {
+	RegisterUDPPortsWithLayerType(PhotonLayerTypeDropInvalidCrc, 5056)
+	defer RegisterUDPPorts()
+
+	data := serializeUDPPacket(t, PhotonLayer{
+		PeerID:     1,
+		CrcEnabled: PhotonCrcEnabled,
+		Commands:   []PhotonCommand{{Type: AcknowledgeType, Data: []byte{}}},
+	})
+
+	data[len(data)-1] ^= 0xff
+	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+
+	if packet.Layer(PhotonLayerType) != nil {
+		t.Errorf("Photon layer should be absent")
+	}
+
+	if failure, ok := packet.ErrorLayer().(*gopacket.DecodeFailure); !ok || failure.Error() != ErrCrcMismatch {
+		t.Errorf("Expected %v", ErrCrcMismatch)
+	}
+}
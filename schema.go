@@ -0,0 +1,243 @@
+package photon_spectator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Names the operations and events of a game and their paramaters. Schemas are
+// read from JSON such as:
+//
+//	{
+//		"operations": {
+//			"2": {"name": "Move",
+//				"request": {"1": {"name": "position", "type": "float32[]", "required": true}},
+//				"response": {"0": {"name": "accepted", "type": "bool"}}
+//			}
+//		},
+//		"events": {
+//			"3": {"name": "Leave", "paramaters": {"0": {"name": "actor", "type": "int32"}}}
+//		}
+//	}
+//
+// Types are named as in TypedValue. Paramaters without a type accept any value.
+type Schema struct {
+	Operations map[uint8]OperationSchema `json:"operations"`
+	Events     map[uint8]MessageSchema   `json:"events"`
+}
+
+// Names an operation and the paramaters of its requests and responses.
+type OperationSchema struct {
+	Name     string                    `json:"name"`
+	Request  map[uint8]ParamaterSchema `json:"request"`
+	Response map[uint8]ParamaterSchema `json:"response"`
+}
+
+type MessageSchema struct {
+	Name       string                    `json:"name"`
+	Paramaters map[uint8]ParamaterSchema `json:"paramaters"`
+}
+
+type ParamaterSchema struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
+
+// Reports a message whose code the schema doesn't name.
+type UnknownCodeError struct {
+	// EventDataType, OperationRequest or OperationResponse
+	MessageType uint8
+	Code        uint8
+}
+
+func (e *UnknownCodeError) Error() string {
+	if e.MessageType == EventDataType {
+		return fmt.Sprintf("Unknown event code of %d", e.Code)
+	}
+
+	return fmt.Sprintf("Unknown operation code of %d", e.Code)
+}
+
+// A paramater of a message along with the name given to it by a schema.
+type AnnotatedParamater struct {
+	ID    uint8
+	Name  string
+	Value interface{}
+}
+
+// A message along with the names given to it and its paramaters by a schema.
+type Annotation struct {
+	// Empty when the schema doesn't name the message's code
+	Name string
+	// Ordered by ID. Paramaters the schema doesn't name have an empty Name.
+	Paramaters []AnnotatedParamater
+}
+
+// Returns the name given to the paramater, or its ID when it has none.
+func (p AnnotatedParamater) String() string {
+	if p.Name == "" {
+		return strconv.Itoa(int(p.ID))
+	}
+
+	return p.Name
+}
+
+var schemaTypes = map[string]bool{
+	"nil": true, "byte": true, "int8": true, "bool": true, "int16": true, "int32": true, "int64": true,
+	"float32": true, "double": true, "string": true, "object": true, "hashtable": true,
+	"event": true, "request": true, "response": true, "custom": true,
+}
+
+// Reads a schema from a JSON file.
+func LoadSchema(path string) (*Schema, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ReadSchema(file)
+}
+
+// Reads a schema from JSON. Errors if a paramater's type isn't a known type.
+func ReadSchema(r io.Reader) (*Schema, error) {
+	var schema Schema
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&schema); err != nil {
+		return nil, fmt.Errorf("Invalid schema: %s", err.Error())
+	}
+
+	for code, operation := range schema.Operations {
+		for _, params := range []map[uint8]ParamaterSchema{operation.Request, operation.Response} {
+			if err := checkParamaterTypes(code, params); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for code, event := range schema.Events {
+		if err := checkParamaterTypes(code, event.Paramaters); err != nil {
+			return nil, err
+		}
+	}
+
+	return &schema, nil
+}
+
+func checkParamaterTypes(code uint8, params map[uint8]ParamaterSchema) error {
+	for id, param := range params {
+		if param.Type != "" && !schemaTypes[elementType(param.Type)] {
+			return fmt.Errorf("Invalid schema: paramater %d of code %d has unknown type %q", id, code, param.Type)
+		}
+	}
+
+	return nil
+}
+
+// Returns the name of the innermost element type of a slice type.
+func elementType(name string) string {
+	for strings.HasSuffix(name, "[]") {
+		name = strings.TrimSuffix(name, "[]")
+	}
+
+	return name
+}
+
+// Returns the schema of a message by its event or operation code. Operation
+// requests and responses take the paramaters of their side of the operation.
+func (s *Schema) Lookup(header ReliableMessage) (MessageSchema, bool) {
+	switch header.Type {
+	case EventDataType:
+		message, ok := s.Events[header.EventCode]
+		return message, ok
+	case OperationRequest:
+		operation, ok := s.Operations[header.OperationCode]
+		return MessageSchema{operation.Name, operation.Request}, ok
+	case OperationResponse:
+		operation, ok := s.Operations[header.OperationCode]
+		return MessageSchema{operation.Name, operation.Response}, ok
+	default:
+		return MessageSchema{}, false
+	}
+}
+
+// Returns the names the schema gives a message and its paramaters.
+func (s *Schema) Annotate(header ReliableMessage, params ReliableMessageParamaters) Annotation {
+	var annotation Annotation
+
+	message, _ := s.Lookup(header)
+	annotation.Name = message.Name
+
+	for key, value := range params {
+		id, err := strconv.Atoi(key)
+
+		if err != nil || id < 0 || id > 255 {
+			continue
+		}
+
+		annotation.Paramaters = append(annotation.Paramaters, AnnotatedParamater{
+			ID:    uint8(id),
+			Name:  message.Paramaters[uint8(id)].Name,
+			Value: value,
+		})
+	}
+
+	sort.Slice(annotation.Paramaters, func(i, j int) bool {
+		return annotation.Paramaters[i].ID < annotation.Paramaters[j].ID
+	})
+
+	return annotation
+}
+
+// Checks a message against its schema, returning each paramater which is
+// missing or of the wrong type. Paramaters the schema doesn't name are
+// allowed. Returns an UnknownCodeError when the schema doesn't name the
+// message's code.
+func (s *Schema) Validate(header ReliableMessage, params ReliableMessageParamaters) []error {
+	message, ok := s.Lookup(header)
+
+	if !ok {
+		code := header.OperationCode
+
+		if header.Type == EventDataType {
+			code = header.EventCode
+		}
+
+		return []error{&UnknownCodeError{header.Type, code}}
+	}
+
+	ids := make([]int, 0, len(message.Paramaters))
+
+	for id := range message.Paramaters {
+		ids = append(ids, int(id))
+	}
+
+	sort.Ints(ids)
+
+	var errs []error
+
+	for _, id := range ids {
+		param := message.Paramaters[uint8(id)]
+		value, present := params[strconv.Itoa(id)]
+
+		switch {
+		case !present && param.Required:
+			errs = append(errs, fmt.Errorf("%s: paramater %d (%s) is missing", message.Name, id, param.Name))
+		case present && param.Type != "" && TypeName(value) != param.Type:
+			errs = append(errs, fmt.Errorf("%s: paramater %d (%s) is %s, expected %s", message.Name, id, param.Name, TypeName(value), param.Type))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,118 @@
+package photon_spectator
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"operations": {
+		"2": {"name": "Move",
+			"request": {
+				"1": {"name": "position", "type": "float32[]", "required": true},
+				"2": {"name": "speed", "type": "double"}
+			},
+			"response": {"1": {"name": "accepted", "type": "bool", "required": true}}
+		}
+	},
+	"events": {
+		"3": {"name": "Leave", "paramaters": {"0": {"name": "actor"}}}
+	}
+}`
+
+func TestLoadSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	ioutil.WriteFile(path, []byte(testSchema), 0644)
+
+	schema, err := LoadSchema(path)
+
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if schema.Operations[2].Request[1].Type != "float32[]" || !schema.Operations[2].Response[1].Required || schema.Events[3].Name != "Leave" {
+		t.Errorf("Schema invalid: %+v", schema)
+	}
+
+	invalid := []string{
+		`{"operations": {"2": {"name": "Move", "request": {"1": {"type": "float"}}}}}`,
+		`{"operations": {"2": {"name": "Move", "response": {"1": {"type": "float"}}}}}`,
+		`{"operations": {"2": {"name": "Move", "paramaters": {}}}}`,
+		`{"operations": {"256": {"name": "Move"}}}`,
+		`{"operation": {}}`,
+	}
+
+	for _, data := range invalid {
+		if _, err := ReadSchema(strings.NewReader(data)); err == nil {
+			t.Errorf("Expected %s to be invalid", data)
+		}
+	}
+}
+
+func TestSchema_Annotate(t *testing.T) {
+	schema, _ := ReadSchema(strings.NewReader(testSchema))
+
+	annotation := schema.Annotate(ReliableMessage{Type: OperationRequest, OperationCode: 2},
+		ReliableMessageParamaters{"2": float64(1), "1": []float32{0}, "252": int16(2)})
+
+	expected := Annotation{
+		Name: "Move",
+		Paramaters: []AnnotatedParamater{
+			{1, "position", []float32{0}},
+			{2, "speed", float64(1)},
+			{252, "", int16(2)},
+		},
+	}
+
+	if !reflect.DeepEqual(expected, annotation) {
+		t.Errorf("Expected %+v but got %+v", expected, annotation)
+	}
+
+	if annotation.Paramaters[2].String() != "252" {
+		t.Errorf("Expected unnamed paramaters to be called by their ID")
+	}
+
+	if schema.Annotate(ReliableMessage{Type: EventDataType, EventCode: 2}, nil).Name != "" {
+		t.Errorf("Expected unknown codes to be unnamed")
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	schema, _ := ReadSchema(strings.NewReader(testSchema))
+
+	validations := []struct {
+		header ReliableMessage
+		params ReliableMessageParamaters
+		output []string
+	}{
+		{ReliableMessage{Type: OperationResponse, OperationCode: 2}, ReliableMessageParamaters{"1": true}, nil},
+		{ReliableMessage{Type: OperationResponse, OperationCode: 2}, ReliableMessageParamaters{"1": []float32{}},
+			[]string{"Move: paramater 1 (accepted) is float32[], expected bool"}},
+		{ReliableMessage{Type: EventDataType, EventCode: 3}, ReliableMessageParamaters{"0": "any"}, nil},
+		{ReliableMessage{Type: OperationRequest, OperationCode: 2}, ReliableMessageParamaters{"2": float32(1)},
+			[]string{"Move: paramater 1 (position) is missing", "Move: paramater 2 (speed) is float32, expected double"}},
+		{ReliableMessage{Type: EventDataType, EventCode: 2}, nil, []string{"Unknown event code of 2"}},
+		{ReliableMessage{Type: OperationRequest, OperationCode: 3}, nil, []string{"Unknown operation code of 3"}},
+	}
+
+	for _, v := range validations {
+		var actual []string
+
+		for _, err := range schema.Validate(v.header, v.params) {
+			actual = append(actual, err.Error())
+		}
+
+		if !reflect.DeepEqual(v.output, actual) {
+			t.Errorf("Expected %v but got %v", v.output, actual)
+		}
+	}
+
+	errs := schema.Validate(ReliableMessage{Type: EventDataType, EventCode: 9}, nil)
+
+	if unknown, ok := errs[0].(*UnknownCodeError); !ok || unknown.Code != 9 {
+		t.Errorf("Expected an UnknownCodeError")
+	}
+}
@@ -0,0 +1,238 @@
+package photon_spectator
+
+import (
+	"sort"
+	"time"
+)
+
+// Orders the reliable commands of each connection and channel by their
+// ReliableSequenceNumber, dropping retransmits. Unreliable commands pass
+// through untouched.
+type Sequencer struct {
+	options  SequencerOptions
+	channels map[sequencerKey]*sequencerChannel
+	now      time.Time
+}
+
+// Furthest ahead of the next expected command a Sequencer buffers commands by
+// default.
+const DefaultSequencerWindow = 1024
+
+type SequencerOptions struct {
+	// Time to wait for a missing command before skipping past it, as measured
+	// by the times passed to Advance. Zero waits indefinitely.
+	GapTimeout time.Duration
+	// Furthest ahead of the next expected sequence number a command is
+	// buffered, so that a corrupt sequence number can't make the channel skip
+	// past every later command. Defaults to DefaultSequencerWindow.
+	Window int
+	// Called with each range of commands skipped
+	OnGap func(SequenceGap)
+	// Called with each command dropped for being beyond the window
+	OnOutOfWindow func(ConnectionKey, PhotonCommand)
+}
+
+// A range of reliable commands which never arrived and were skipped.
+type SequenceGap struct {
+	Connection ConnectionKey
+	ChannelID  uint8
+	// First and last missing sequence numbers
+	From int32
+	To   int32
+}
+
+type sequencerKey struct {
+	connection ConnectionKey
+	channelID  uint8
+}
+
+type sequencerChannel struct {
+	next         int32
+	pending      map[int32]PhotonCommand
+	waitingSince time.Time
+}
+
+// Makes a new instance of a Sequencer
+func NewSequencer(options SequencerOptions) *Sequencer {
+	if options.Window <= 0 {
+		options.Window = DefaultSequencerWindow
+	}
+
+	return &Sequencer{
+		options:  options,
+		channels: make(map[sequencerKey]*sequencerChannel),
+	}
+}
+
+// Offers a command sent on the given connection to the sequencer. Returns the
+// commands which can now be delivered in order, which is empty when the
+// command is a retransmit, arrived ahead of a missing command or is beyond the
+// window. The first reliable command seen on a channel sets where its sequence
+// starts.
+func (s *Sequencer) Offer(connection ConnectionKey, command PhotonCommand) []PhotonCommand {
+	if !command.IsReliable() {
+		return []PhotonCommand{command}
+	}
+
+	key := sequencerKey{connection, command.ChannelID}
+	channel, ok := s.channels[key]
+
+	if !ok {
+		channel = &sequencerChannel{
+			next:    command.ReliableSequenceNumber,
+			pending: make(map[int32]PhotonCommand),
+		}
+		s.channels[key] = channel
+	}
+
+	sequenceNumber := command.ReliableSequenceNumber
+
+	if sequenceNumber < channel.next {
+		return nil
+	}
+
+	if int64(sequenceNumber)-int64(channel.next) > int64(s.options.Window) {
+		if s.options.OnOutOfWindow != nil {
+			s.options.OnOutOfWindow(connection, command)
+		}
+
+		return nil
+	}
+
+	if _, ok := channel.pending[sequenceNumber]; ok {
+		return nil
+	}
+
+	channel.pending[sequenceNumber] = command
+
+	if sequenceNumber != channel.next {
+		if channel.waitingSince.IsZero() {
+			channel.waitingSince = s.now
+		}
+
+		return nil
+	}
+
+	return s.release(channel, nil)
+}
+
+// Sets the current time of the sequencer, normally the capture time of the
+// latest packet, and skips any gaps which have been waited on for longer than
+// the timeout. Returns the commands released by skipping gaps.
+func (s *Sequencer) Advance(now time.Time) []PhotonCommand {
+	var released []PhotonCommand
+
+	s.advance(now, func(connection ConnectionKey, commands []PhotonCommand) {
+		released = append(released, commands...)
+	})
+
+	return released
+}
+
+// Advances the sequencer, passing the commands released on each channel along
+// with the connection they were sent on.
+func (s *Sequencer) advance(now time.Time, release func(ConnectionKey, []PhotonCommand)) {
+	s.now = now
+
+	if s.options.GapTimeout <= 0 {
+		return
+	}
+
+	for key, channel := range s.channels {
+		if len(channel.pending) == 0 || now.Sub(channel.waitingSince) <= s.options.GapTimeout {
+			continue
+		}
+
+		release(key.connection, s.skip(key, channel))
+	}
+}
+
+// Releases every command held for a connection in order, skipping past and
+// reporting any gaps, then removes the state of its channels.
+func (s *Sequencer) Flush(connection ConnectionKey) []PhotonCommand {
+	var released []PhotonCommand
+
+	s.flush(connection, func(connection ConnectionKey, commands []PhotonCommand) {
+		released = append(released, commands...)
+	})
+
+	return released
+}
+
+func (s *Sequencer) flush(connection ConnectionKey, release func(ConnectionKey, []PhotonCommand)) {
+	var keys []sequencerKey
+
+	for key := range s.channels {
+		if key.connection == connection {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].channelID < keys[j].channelID
+	})
+
+	for _, key := range keys {
+		channel := s.channels[key]
+
+		for len(channel.pending) > 0 {
+			release(key.connection, s.skip(key, channel))
+		}
+
+		delete(s.channels, key)
+	}
+}
+
+// Skips to the earliest pending command of a channel, reporting the gap, and
+// returns the commands released.
+func (s *Sequencer) skip(key sequencerKey, channel *sequencerChannel) []PhotonCommand {
+	sequenceNumbers := make([]int, 0, len(channel.pending))
+
+	for sequenceNumber := range channel.pending {
+		sequenceNumbers = append(sequenceNumbers, int(sequenceNumber))
+	}
+
+	sort.Ints(sequenceNumbers)
+	resume := int32(sequenceNumbers[0])
+
+	if s.options.OnGap != nil {
+		s.options.OnGap(SequenceGap{key.connection, key.channelID, channel.next, resume - 1})
+	}
+
+	channel.next = resume
+	return s.release(channel, nil)
+}
+
+// Removes the state of every channel of a connection, dropping any commands
+// held for it.
+func (s *Sequencer) Remove(connection ConnectionKey) {
+	for key := range s.channels {
+		if key.connection == connection {
+			delete(s.channels, key)
+		}
+	}
+}
+
+// Appends the consecutive pending commands starting at the next expected
+// sequence number.
+func (s *Sequencer) release(channel *sequencerChannel, released []PhotonCommand) []PhotonCommand {
+	for {
+		command, ok := channel.pending[channel.next]
+
+		if !ok {
+			break
+		}
+
+		released = append(released, command)
+		delete(channel.pending, channel.next)
+		channel.next++
+	}
+
+	channel.waitingSince = time.Time{}
+
+	if len(channel.pending) > 0 {
+		channel.waitingSince = s.now
+	}
+
+	return released
+}
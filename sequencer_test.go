@@ -0,0 +1,181 @@
+package photon_spectator
+
+import (
+	"testing"
+	"time"
+)
+
+func reliableCommand(sequenceNumber int32) PhotonCommand {
+	return PhotonCommand{
+		Type:                   SendReliableType,
+		Flags:                  ReliableFlag,
+		ReliableSequenceNumber: sequenceNumber,
+	}
+}
+
+func sequenceNumbers(commands []PhotonCommand) []int32 {
+	var numbers []int32
+
+	for _, command := range commands {
+		numbers = append(numbers, command.ReliableSequenceNumber)
+	}
+
+	return numbers
+}
+
+func TestSequencer(t *testing.T) {
+	sequencer := NewSequencer(SequencerOptions{})
+
+	var released []PhotonCommand
+
+	for _, sequenceNumber := range []int32{1, 3, 1, 4, 2, 3, 5} {
+		released = append(released, sequencer.Offer(ConnectionKey{}, reliableCommand(sequenceNumber))...)
+	}
+
+	actual := sequenceNumbers(released)
+	expected := []int32{1, 2, 3, 4, 5}
+
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %v but got %v", expected, actual)
+	}
+
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("Expected %v but got %v", expected, actual)
+		}
+	}
+}
+
+func TestSequencer_Unreliable(t *testing.T) {
+	sequencer := NewSequencer(SequencerOptions{})
+	sequencer.Offer(ConnectionKey{}, reliableCommand(1))
+
+	unreliable := PhotonCommand{Type: SendUnreliableType, ReliableSequenceNumber: 1}
+
+	if len(sequencer.Offer(ConnectionKey{}, unreliable)) != 1 {
+		t.Errorf("Expected unreliable commands to pass through")
+	}
+}
+
+func TestSequencer_Channels(t *testing.T) {
+	sequencer := NewSequencer(SequencerOptions{})
+	other := reliableCommand(1)
+	other.ChannelID = 1
+
+	sequencer.Offer(ConnectionKey{}, reliableCommand(1))
+
+	if len(sequencer.Offer(ConnectionKey{}, other)) != 1 {
+		t.Errorf("Expected channels to be sequenced separately")
+	}
+
+	if len(sequencer.Offer(ConnectionKey{PeerID: 1}, reliableCommand(1))) != 1 {
+		t.Errorf("Expected connections to be sequenced separately")
+	}
+}
+
+func TestSequencer_GapTimeout(t *testing.T) {
+	var gaps []SequenceGap
+
+	sequencer := NewSequencer(SequencerOptions{
+		GapTimeout: time.Second,
+		OnGap:      func(gap SequenceGap) { gaps = append(gaps, gap) },
+	})
+
+	start := time.Unix(1500000000, 0)
+
+	sequencer.Advance(start)
+	sequencer.Offer(ConnectionKey{}, reliableCommand(1))
+	sequencer.Offer(ConnectionKey{}, reliableCommand(4))
+	sequencer.Offer(ConnectionKey{}, reliableCommand(5))
+
+	if released := sequencer.Advance(start.Add(time.Second)); len(released) != 0 {
+		t.Errorf("Expected commands to be held until the timeout")
+	}
+
+	released := sequencer.Advance(start.Add(2 * time.Second))
+	actual := sequenceNumbers(released)
+
+	if len(actual) != 2 || actual[0] != 4 || actual[1] != 5 {
+		t.Errorf("Expected [4 5] but got %v", actual)
+	}
+
+	if len(gaps) != 1 || gaps[0].From != 2 || gaps[0].To != 3 {
+		t.Errorf("Gap invalid: %#v", gaps)
+	}
+
+	if len(sequencer.Offer(ConnectionKey{}, reliableCommand(3))) != 0 {
+		t.Errorf("Expected skipped commands to be dropped")
+	}
+
+	if len(sequencer.Offer(ConnectionKey{}, reliableCommand(6))) != 1 {
+		t.Errorf("Expected sequencing to continue after the gap")
+	}
+}
+
+func TestSequencer_Window(t *testing.T) {
+	var dropped []int32
+
+	sequencer := NewSequencer(SequencerOptions{
+		GapTimeout: time.Second,
+		Window:     10,
+		OnOutOfWindow: func(connection ConnectionKey, command PhotonCommand) {
+			dropped = append(dropped, command.ReliableSequenceNumber)
+		},
+	})
+
+	start := time.Unix(1500000000, 0)
+
+	sequencer.Advance(start)
+	sequencer.Offer(ConnectionKey{}, reliableCommand(1))
+	sequencer.Offer(ConnectionKey{}, reliableCommand(1000000))
+
+	if len(dropped) != 1 || dropped[0] != 1000000 {
+		t.Errorf("Expected the command beyond the window to be dropped but got %v", dropped)
+	}
+
+	sequencer.Advance(start.Add(2 * time.Second))
+
+	if len(sequencer.Offer(ConnectionKey{}, reliableCommand(2))) != 1 {
+		t.Errorf("Expected the dropped command not to move the sequence")
+	}
+
+	sequencer.Offer(ConnectionKey{}, reliableCommand(13))
+
+	if len(dropped) != 1 {
+		t.Errorf("Expected commands within the window to be buffered but got %v", dropped)
+	}
+}
+
+func TestSequencer_Flush(t *testing.T) {
+	var gaps []SequenceGap
+
+	sequencer := NewSequencer(SequencerOptions{OnGap: func(gap SequenceGap) { gaps = append(gaps, gap) }})
+
+	for _, sequenceNumber := range []int32{1, 5, 3} {
+		sequencer.Offer(ConnectionKey{}, reliableCommand(sequenceNumber))
+	}
+
+	actual := sequenceNumbers(sequencer.Flush(ConnectionKey{}))
+
+	if len(actual) != 2 || actual[0] != 3 || actual[1] != 5 {
+		t.Errorf("Expected [3 5] but got %v", actual)
+	}
+
+	if len(gaps) != 2 || gaps[0].From != 2 || gaps[0].To != 2 || gaps[1].From != 4 || gaps[1].To != 4 {
+		t.Errorf("Gaps invalid: %#v", gaps)
+	}
+
+	if len(sequencer.Offer(ConnectionKey{}, reliableCommand(9))) != 1 {
+		t.Errorf("Expected the sequence to restart")
+	}
+}
+
+func TestSequencer_Remove(t *testing.T) {
+	sequencer := NewSequencer(SequencerOptions{})
+	sequencer.Offer(ConnectionKey{}, reliableCommand(5))
+	sequencer.Remove(ConnectionKey{})
+
+	if len(sequencer.Offer(ConnectionKey{}, reliableCommand(1))) != 1 {
+		t.Errorf("Expected the sequence to restart")
+	}
+}
@@ -0,0 +1,209 @@
+package photon_spectator
+
+import (
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// The stage of its lifecycle a session is in.
+type SessionState uint8
+
+const (
+	// The client has sent Connect
+	SessionConnecting SessionState = iota
+	// The server has sent VerifyConnect, assigning the client a PeerID
+	SessionVerified
+	// Either side has sent a command other than the handshake
+	SessionActive
+	// Either side has sent Disconnect
+	SessionDisconnected
+	// Neither side has sent a packet within the timeout
+	SessionTimedOut
+)
+
+// A connection between a Photon client and server, from the client's Connect
+// until it disconnects or times out.
+type Session struct {
+	// Flows from the client to the server
+	Network   gopacket.Flow
+	Transport gopacket.Flow
+
+	// Assigned by the server's VerifyConnect
+	PeerID uint16
+
+	State    SessionState
+	Started  time.Time
+	LastSeen time.Time
+
+	// Every connection packets of the session were seen on
+	connections map[ConnectionKey]bool
+}
+
+// A session entering a new state.
+type SessionEvent struct {
+	Session Session
+	Time    time.Time
+}
+
+type SessionTrackerOptions struct {
+	// Time after which a session neither side has sent a packet on times out,
+	// as measured by the times passed to Advance. Zero never times out.
+	Timeout time.Duration
+	// Called each time a session enters a new state
+	OnEvent func(SessionEvent)
+	// Buffers whose state for a session is removed when it ends. Commands the
+	// Sequencer holds behind a gap are first released in order.
+	FragmentBuffer *FragmentBuffer
+	Sequencer      *Sequencer
+	// Called with the commands released for each connection of an ending
+	// session
+	OnRelease func(ConnectionKey, []PhotonCommand)
+}
+
+// Follows sessions through their Connect, VerifyConnect and Disconnect
+// commands. Flows whose Connect wasn't seen aren't tracked, and a Connect on
+// the flows of a live session is taken as a retransmit.
+type SessionTracker struct {
+	options  SessionTrackerOptions
+	sessions map[sessionKey]*Session
+	now      time.Time
+}
+
+type sessionKey struct {
+	network   gopacket.Flow
+	transport gopacket.Flow
+}
+
+// Makes a new instance of a SessionTracker
+func NewSessionTracker(options SessionTrackerOptions) *SessionTracker {
+	return &SessionTracker{
+		options:  options,
+		sessions: make(map[sessionKey]*Session),
+	}
+}
+
+// Updates the session a packet was sent on from its commands. Returns the
+// session, or nil when the packet isn't part of a tracked session.
+func (t *SessionTracker) Observe(connection ConnectionKey, layer PhotonLayer) *Session {
+	key := sessionKey{connection.Network, connection.Transport}
+	session, fromClient := t.lookup(connection)
+
+	for _, command := range layer.Commands {
+		switch {
+		case command.Type == ConnectType && session == nil:
+			session = &Session{
+				Network:     connection.Network,
+				Transport:   connection.Transport,
+				Started:     t.now,
+				connections: make(map[ConnectionKey]bool),
+			}
+
+			fromClient = true
+			t.sessions[key] = session
+			t.transition(session, SessionConnecting)
+		case session == nil:
+			continue
+		case command.Type == ConnectType:
+			// Connect is resent until acknowledged, so a live session is only
+			// replaced once it has disconnected or timed out
+			continue
+		case command.Type == VerifyConnectType && !fromClient && session.State == SessionConnecting:
+			session.PeerID = layer.PeerID
+
+			if len(command.Data) >= 2 {
+				verify, _ := command.VerifyConnect()
+				session.PeerID = verify.PeerID
+			}
+
+			t.transition(session, SessionVerified)
+		case command.Type == DisconnectType:
+			session.LastSeen = t.now
+			session.connections[connection] = true
+			t.end(session, SessionDisconnected)
+			return session
+		case command.Type != AcknowledgeType && command.Type != PingType && session.State == SessionVerified:
+			t.transition(session, SessionActive)
+		}
+	}
+
+	if session != nil {
+		session.LastSeen = t.now
+		session.connections[connection] = true
+	}
+
+	return session
+}
+
+// Sets the current time of the tracker, normally the capture time of the
+// latest packet, and times out sessions which have been quiet for longer than
+// the timeout.
+func (t *SessionTracker) Advance(now time.Time) {
+	t.now = now
+
+	if t.options.Timeout <= 0 {
+		return
+	}
+
+	for _, session := range t.sessions {
+		if now.Sub(session.LastSeen) > t.options.Timeout {
+			t.end(session, SessionTimedOut)
+		}
+	}
+}
+
+// Returns the sessions which haven't yet ended.
+func (t *SessionTracker) Sessions() []*Session {
+	sessions := make([]*Session, 0, len(t.sessions))
+
+	for _, session := range t.sessions {
+		sessions = append(sessions, session)
+	}
+
+	return sessions
+}
+
+// Returns the tracked session a connection is part of, if any, and whether the
+// connection is from its client.
+func (t *SessionTracker) lookup(connection ConnectionKey) (*Session, bool) {
+	if session, ok := t.sessions[sessionKey{connection.Network, connection.Transport}]; ok {
+		return session, true
+	}
+
+	return t.sessions[sessionKey{connection.Network.Reverse(), connection.Transport.Reverse()}], false
+}
+
+func (t *SessionTracker) release(connection ConnectionKey, commands []PhotonCommand) {
+	if len(commands) > 0 && t.options.OnRelease != nil {
+		t.options.OnRelease(connection, commands)
+	}
+}
+
+func (t *SessionTracker) transition(session *Session, state SessionState) {
+	session.State = state
+
+	if t.options.OnEvent != nil {
+		t.options.OnEvent(SessionEvent{Session: *session, Time: t.now})
+	}
+}
+
+// Stops tracking a session and frees any state kept for its connections.
+func (t *SessionTracker) end(session *Session, state SessionState) {
+	// Released commands are passed on while the session can still tell which
+	// side sent them, and before the fragments they may complete are dropped
+	if t.options.Sequencer != nil {
+		for connection := range session.connections {
+			t.options.Sequencer.flush(connection, t.release)
+		}
+	}
+
+	if t.options.FragmentBuffer != nil {
+		for connection := range session.connections {
+			t.options.FragmentBuffer.Remove(connection)
+		}
+	}
+
+	delete(t.sessions, sessionKey{session.Network, session.Transport})
+
+	t.transition(session, state)
+}
@@ -0,0 +1,190 @@
+package photon_spectator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var clientConnection = ConnectionKey{
+	Network:   gopacket.NewFlow(layers.EndpointIPv4, []byte{10, 0, 0, 1}, []byte{10, 0, 0, 2}),
+	Transport: gopacket.NewFlow(layers.EndpointUDPPort, []byte{0xc3, 0x50}, []byte{0x13, 0xc0}),
+}
+
+var serverConnection = ConnectionKey{
+	Network:   clientConnection.Network.Reverse(),
+	Transport: clientConnection.Transport.Reverse(),
+}
+
+func commandLayer(peerID uint16, commands ...PhotonCommand) PhotonLayer {
+	return PhotonLayer{PeerID: peerID, CommandCount: uint8(len(commands)), Commands: commands}
+}
+
+func TestSessionTracker(t *testing.T) {
+	var states []SessionState
+
+	tracker := NewSessionTracker(SessionTrackerOptions{
+		OnEvent: func(event SessionEvent) { states = append(states, event.Session.State) },
+	})
+
+	if tracker.Observe(clientConnection, commandLayer(0, PhotonCommand{Type: SendReliableType})) != nil {
+		t.Errorf("Expected flows without a Connect to be untracked")
+	}
+
+	tracker.Observe(clientConnection, commandLayer(0xffff, PhotonCommand{Type: ConnectType}))
+	session := tracker.Observe(serverConnection, commandLayer(0, PhotonCommand{Type: VerifyConnectType, Data: []byte{0x00, 0x07}}))
+
+	if session == nil || session.PeerID != 7 {
+		t.Fatalf("Expected the assigned PeerID to be captured")
+	}
+
+	tracker.Observe(serverConnection, commandLayer(7, PhotonCommand{Type: AcknowledgeType}))
+	tracker.Observe(clientConnection, commandLayer(7, PhotonCommand{Type: SendReliableType}))
+
+	if len(tracker.Sessions()) != 1 {
+		t.Errorf("Expected one session")
+	}
+
+	tracker.Observe(clientConnection, commandLayer(7, PhotonCommand{Type: DisconnectType}))
+
+	expected := []SessionState{SessionConnecting, SessionVerified, SessionActive, SessionDisconnected}
+
+	if len(states) != len(expected) {
+		t.Fatalf("Expected %v but got %v", expected, states)
+	}
+
+	for i := range expected {
+		if states[i] != expected[i] {
+			t.Fatalf("Expected %v but got %v", expected, states)
+		}
+	}
+
+	if len(tracker.Sessions()) != 0 {
+		t.Errorf("Expected the session to end")
+	}
+}
+
+func TestSessionTracker_Timeout(t *testing.T) {
+	var events []SessionEvent
+
+	sequencer := NewSequencer(SequencerOptions{})
+	buffer := NewFragmentBuffer()
+
+	tracker := NewSessionTracker(SessionTrackerOptions{
+		Timeout:        time.Second,
+		OnEvent:        func(event SessionEvent) { events = append(events, event) },
+		FragmentBuffer: buffer,
+		Sequencer:      sequencer,
+	})
+
+	start := time.Unix(1500000000, 0)
+	tracker.Advance(start)
+	tracker.Observe(clientConnection, commandLayer(0xffff, PhotonCommand{Type: ConnectType}))
+
+	connection := clientConnection
+	connection.PeerID = 7
+	tracker.Observe(connection, commandLayer(7, reliableCommand(5)))
+	sequencer.Offer(connection, reliableCommand(5))
+	buffer.OfferFrom(connection, 0, ReliableFragment{SequenceNumber: 1, FragmentCount: 2, TotalLength: 2, Data: []byte{0x01}})
+
+	tracker.Advance(start.Add(time.Second))
+
+	if len(tracker.Sessions()) != 1 {
+		t.Fatalf("Expected the session to be held until the timeout")
+	}
+
+	tracker.Advance(start.Add(2 * time.Second))
+
+	if len(tracker.Sessions()) != 0 {
+		t.Fatalf("Expected the session to time out")
+	}
+
+	last := events[len(events)-1]
+
+	if last.Session.State != SessionTimedOut || !last.Time.Equal(start.Add(2*time.Second)) {
+		t.Errorf("Event invalid: %#v", last)
+	}
+
+	if len(sequencer.Offer(connection, reliableCommand(1))) != 1 {
+		t.Errorf("Expected sequencing state to be freed")
+	}
+
+	fragment := ReliableFragment{SequenceNumber: 1, FragmentNumber: 1, FragmentCount: 2, TotalLength: 2, FragmentOffset: 1, Data: []byte{0x02}}
+
+	if response, _ := buffer.OfferFrom(connection, 0, fragment); response != nil {
+		t.Errorf("Expected reassembly state to be freed")
+	}
+}
+
+func TestSessionTracker_RetransmittedConnect(t *testing.T) {
+	var states []SessionState
+
+	sequencer := NewSequencer(SequencerOptions{})
+
+	tracker := NewSessionTracker(SessionTrackerOptions{
+		OnEvent:   func(event SessionEvent) { states = append(states, event.Session.State) },
+		Sequencer: sequencer,
+	})
+
+	connect := PhotonCommand{Type: ConnectType, Flags: ReliableFlag, ReliableSequenceNumber: 1}
+
+	tracker.Observe(clientConnection, commandLayer(0xffff, connect))
+	tracker.Observe(serverConnection, commandLayer(0, PhotonCommand{Type: VerifyConnectType, Data: []byte{0x00, 0x07}}))
+	tracker.Observe(clientConnection, commandLayer(0xffff, connect))
+
+	sequencer.Offer(clientConnection, reliableCommand(2))
+	session := tracker.Observe(clientConnection, commandLayer(7, reliableCommand(2)))
+	tracker.Observe(clientConnection, commandLayer(0xffff, connect))
+
+	expected := []SessionState{SessionConnecting, SessionVerified, SessionActive}
+
+	if !reflect.DeepEqual(states, expected) {
+		t.Fatalf("Expected %v but got %v", expected, states)
+	}
+
+	if session == nil || session.PeerID != 7 || len(tracker.Sessions()) != 1 {
+		t.Errorf("Expected the session to be kept")
+	}
+
+	if len(sequencer.Offer(clientConnection, reliableCommand(2))) != 0 {
+		t.Errorf("Expected sequencing state to be kept")
+	}
+
+	tracker.Observe(clientConnection, commandLayer(7, PhotonCommand{Type: DisconnectType}))
+	tracker.Observe(clientConnection, commandLayer(0xffff, connect))
+
+	if states[len(states)-1] != SessionConnecting || len(tracker.Sessions()) != 1 {
+		t.Errorf("Expected a Connect after Disconnect to start a new session")
+	}
+}
+
+func TestSessionTracker_ReleasesHeldCommands(t *testing.T) {
+	var released []int32
+
+	sequencer := NewSequencer(SequencerOptions{})
+
+	tracker := NewSessionTracker(SessionTrackerOptions{
+		Timeout:   time.Second,
+		Sequencer: sequencer,
+		OnRelease: func(connection ConnectionKey, commands []PhotonCommand) {
+			released = append(released, sequenceNumbers(commands)...)
+		},
+	})
+
+	start := time.Unix(1500000000, 0)
+	tracker.Advance(start)
+	tracker.Observe(clientConnection, commandLayer(0xffff, PhotonCommand{Type: ConnectType}))
+
+	for _, sequenceNumber := range []int32{5, 7, 8} {
+		sequencer.Offer(clientConnection, reliableCommand(sequenceNumber))
+	}
+
+	tracker.Advance(start.Add(2 * time.Second))
+
+	if len(released) != 2 || released[0] != 7 || released[1] != 8 {
+		t.Errorf("Expected [7 8] to be released but got %v", released)
+	}
+}
@@ -0,0 +1,164 @@
+package photon_spectator
+
+import (
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// Statistics of the reliable commands sent in one direction of a connection.
+type ConnectionStats struct {
+	// Round trips measured from acknowledgements of the sent commands
+	RoundTripSamples      int
+	RoundTripTime         time.Duration
+	SmoothedRoundTripTime time.Duration
+	// Mean deviation between consecutive round trip times
+	Jitter time.Duration
+
+	ReliableCommands int
+	Retransmissions  int
+}
+
+// Returns the fraction of reliable transmissions which were retransmits,
+// approximating the rate at which packets were lost.
+func (s ConnectionStats) Loss() float64 {
+	if s.ReliableCommands+s.Retransmissions == 0 {
+		return 0
+	}
+
+	return float64(s.Retransmissions) / float64(s.ReliableCommands+s.Retransmissions)
+}
+
+// Collects ConnectionStats for each direction of each connection from the
+// packets observed. Directions are identified by their network and transport
+// flows alone, as the PeerID of a connection differs between directions.
+type StatsTracker struct {
+	directions map[statsKey]*statsDirection
+	now        time.Time
+}
+
+type statsKey struct {
+	network   gopacket.Flow
+	transport gopacket.Flow
+}
+
+type statsDirection struct {
+	stats ConnectionStats
+
+	// Highest reliable sequence number sent on each channel
+	highest map[uint8]int32
+
+	// Timestamp and capture time of the latest packet sent, relating the
+	// sender's clock to the capture's
+	hasClock      bool
+	lastTimestamp uint32
+	lastSeen      time.Time
+}
+
+// Makes a new instance of a StatsTracker
+func NewStatsTracker() *StatsTracker {
+	return &StatsTracker{directions: make(map[statsKey]*statsDirection)}
+}
+
+// Sets the current time of the tracker, normally the capture time of the
+// latest packet.
+func (t *StatsTracker) Advance(now time.Time) {
+	t.now = now
+}
+
+// Updates the statistics of the connection a packet was sent on from its
+// commands, and those of the opposite direction from its acknowledgements.
+func (t *StatsTracker) Observe(connection ConnectionKey, layer PhotonLayer) {
+	sender := t.direction(statsKey{connection.Network, connection.Transport})
+	receiver := t.direction(statsKey{connection.Network.Reverse(), connection.Transport.Reverse()})
+
+	sender.hasClock = true
+	sender.lastTimestamp = layer.Timestamp
+	sender.lastSeen = t.now
+
+	for _, command := range layer.Commands {
+		if command.Type == AcknowledgeType {
+			ack, _ := command.Acknowledgement()
+			receiver.acknowledged(ack, t.now)
+			continue
+		}
+
+		if command.IsReliable() {
+			sender.sent(command)
+		}
+	}
+}
+
+// Returns the statistics of the commands sent on a connection.
+func (t *StatsTracker) Stats(connection ConnectionKey) ConnectionStats {
+	if direction, ok := t.directions[statsKey{connection.Network, connection.Transport}]; ok {
+		return direction.stats
+	}
+
+	return ConnectionStats{}
+}
+
+// Removes the statistics of both directions of a connection.
+func (t *StatsTracker) Remove(connection ConnectionKey) {
+	delete(t.directions, statsKey{connection.Network, connection.Transport})
+	delete(t.directions, statsKey{connection.Network.Reverse(), connection.Transport.Reverse()})
+}
+
+func (t *StatsTracker) direction(key statsKey) *statsDirection {
+	direction, ok := t.directions[key]
+
+	if !ok {
+		direction = &statsDirection{highest: make(map[uint8]int32)}
+		t.directions[key] = direction
+	}
+
+	return direction
+}
+
+// Counts a reliable command, treating any at or below the highest sequence
+// number already sent on its channel as a retransmit.
+func (d *statsDirection) sent(command PhotonCommand) {
+	highest, ok := d.highest[command.ChannelID]
+
+	if ok && command.ReliableSequenceNumber <= highest {
+		d.stats.Retransmissions++
+		return
+	}
+
+	d.highest[command.ChannelID] = command.ReliableSequenceNumber
+	d.stats.ReliableCommands++
+}
+
+// Measures a round trip from an acknowledgement of a command this direction
+// sent. The sender's clock at the time the acknowledgement was captured is
+// estimated from its latest packet.
+func (d *statsDirection) acknowledged(ack Acknowledgement, now time.Time) {
+	if !d.hasClock {
+		return
+	}
+
+	elapsed := uint32(now.Sub(d.lastSeen) / time.Millisecond)
+	milliseconds := int32(d.lastTimestamp + elapsed - ack.ReceivedSentTime)
+
+	if milliseconds < 0 {
+		return
+	}
+
+	rtt := time.Duration(milliseconds) * time.Millisecond
+
+	if d.stats.RoundTripSamples == 0 {
+		d.stats.SmoothedRoundTripTime = rtt
+	} else {
+		deviation := rtt - d.stats.RoundTripTime
+
+		if deviation < 0 {
+			deviation = -deviation
+		}
+
+		d.stats.Jitter += (deviation - d.stats.Jitter) / 16
+		d.stats.SmoothedRoundTripTime += (rtt - d.stats.SmoothedRoundTripTime) / 8
+	}
+
+	d.stats.RoundTripTime = rtt
+	d.stats.RoundTripSamples++
+}
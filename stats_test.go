@@ -0,0 +1,81 @@
+package photon_spectator
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func acknowledgeCommand(sequenceNumber int32, sentTime uint32) PhotonCommand {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data, uint32(sequenceNumber))
+	binary.BigEndian.PutUint32(data[4:], sentTime)
+
+	return PhotonCommand{Type: AcknowledgeType, Data: data}
+}
+
+func TestStatsTracker(t *testing.T) {
+	tracker := NewStatsTracker()
+	start := time.Unix(1500000000, 0)
+
+	client := func(timestamp uint32, commands ...PhotonCommand) {
+		layer := commandLayer(7, commands...)
+		layer.Timestamp = timestamp
+		tracker.Observe(clientConnection, layer)
+	}
+
+	server := func(commands ...PhotonCommand) {
+		tracker.Observe(serverConnection, commandLayer(7, commands...))
+	}
+
+	tracker.Advance(start)
+	client(1000, reliableCommand(1))
+
+	tracker.Advance(start.Add(50 * time.Millisecond))
+	server(acknowledgeCommand(1, 1000))
+
+	tracker.Advance(start.Add(100 * time.Millisecond))
+	client(1100, reliableCommand(2))
+
+	tracker.Advance(start.Add(200 * time.Millisecond))
+	client(1200, reliableCommand(2))
+
+	tracker.Advance(start.Add(290 * time.Millisecond))
+	server(acknowledgeCommand(2, 1200))
+
+	stats := tracker.Stats(clientConnection)
+
+	if stats.RoundTripSamples != 2 {
+		t.Errorf("Expected two round trips but got %d", stats.RoundTripSamples)
+	}
+
+	if stats.RoundTripTime != 90*time.Millisecond {
+		t.Errorf("RoundTripTime invalid: %s", stats.RoundTripTime)
+	}
+
+	if stats.SmoothedRoundTripTime != 55*time.Millisecond {
+		t.Errorf("SmoothedRoundTripTime invalid: %s", stats.SmoothedRoundTripTime)
+	}
+
+	if stats.Jitter != 2500*time.Microsecond {
+		t.Errorf("Jitter invalid: %s", stats.Jitter)
+	}
+
+	if stats.ReliableCommands != 2 || stats.Retransmissions != 1 {
+		t.Errorf("Commands invalid: %#v", stats)
+	}
+
+	if stats.Loss() != float64(1)/3 {
+		t.Errorf("Loss invalid: %f", stats.Loss())
+	}
+
+	if tracker.Stats(serverConnection).RoundTripSamples != 0 {
+		t.Errorf("Expected directions to be tracked separately")
+	}
+
+	tracker.Remove(serverConnection)
+
+	if tracker.Stats(clientConnection).ReliableCommands != 0 {
+		t.Errorf("Expected both directions to be removed")
+	}
+}
@@ -0,0 +1,344 @@
+package photon_spectator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// Which side of a connection sent a message.
+type Direction uint8
+
+const (
+	// Neither the session nor the ports identify the sender
+	DirectionUnknown Direction = iota
+	// Sent by the client to the server
+	DirectionToServer
+	// Sent by the server to the client
+	DirectionToClient
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionToServer:
+		return "ToServer"
+	case DirectionToClient:
+		return "ToClient"
+	default:
+		return "Unknown"
+	}
+}
+
+// A decoded event, operation request or operation response.
+type Message struct {
+	Connection ConnectionKey
+	Direction  Direction
+	// Capture time of the packet completing the message
+	Time      time.Time
+	ChannelID uint8
+	// Type of the command which carried the message, which is
+	// SendReliableFragmentType for reassembled messages
+	CommandType uint8
+
+	Header     ReliableMessage
+	Paramaters ReliableMessageParamaters
+}
+
+const (
+	// Time a Stream waits for a missing reliable command by default
+	DefaultGapTimeout = 5 * time.Second
+	// Time after which a Stream drops a quiet session or connection by default
+	DefaultSessionTimeout = time.Minute
+)
+
+type StreamOptions struct {
+	// Serialization protocol of the messages, defaulting to Protocol16
+	Protocol uint8
+	// Ports servers listen on, used to tell the direction of connections whose
+	// Connect wasn't seen. Defaults to DefaultPorts.
+	ServerPorts []uint16
+	// Time after which a session neither side has sent a packet on times out,
+	// and after which the state of a connection outside of a tracked session
+	// that hasn't sent a packet is dropped. Defaults to DefaultSessionTimeout.
+	SessionTimeout time.Duration
+	// Called each time a session enters a new state
+	OnSession func(SessionEvent)
+	// Called with each command or message which couldn't be decoded
+	OnError func(error)
+
+	FragmentBuffer FragmentBufferOptions
+	// GapTimeout defaults to DefaultGapTimeout
+	Sequencer SequencerOptions
+}
+
+// A Stream checks for timeouts once this fraction of its smallest timeout has
+// passed in capture time rather than on every packet, as each check visits
+// every connection. Timeouts may fire up to that much late.
+const streamSweepDivisor = 8
+
+// Decodes the messages of the Photon packets read from a source. Reliable
+// commands are delivered in order, fragmented messages are reassembled and
+// sessions are followed to tell which side sent each message.
+type Stream struct {
+	source    *gopacket.PacketSource
+	options   StreamOptions
+	fragments *FragmentBuffer
+	sequencer *Sequencer
+	sessions  *SessionTracker
+	// Capture time of the latest packet sent on each connection
+	lastSeen map[ConnectionKey]time.Time
+	// Capture time of the packet being processed
+	now time.Time
+	// Capture time of the next check for timeouts, and the time between checks
+	nextSweep     time.Time
+	sweepInterval time.Duration
+	// Messages released by sessions ending, yet to be returned
+	released []Message
+}
+
+// Makes a new instance of a Stream reading from the given source. The source
+// may be nil when packets are only passed to Process.
+func NewStream(source *gopacket.PacketSource, options StreamOptions) *Stream {
+	if options.Protocol == 0 {
+		options.Protocol = Protocol16
+	}
+
+	if options.ServerPorts == nil {
+		options.ServerPorts = DefaultPorts
+	}
+
+	if options.SessionTimeout <= 0 {
+		options.SessionTimeout = DefaultSessionTimeout
+	}
+
+	if options.Sequencer.GapTimeout <= 0 {
+		options.Sequencer.GapTimeout = DefaultGapTimeout
+	}
+
+	s := &Stream{
+		source:    source,
+		options:   options,
+		fragments: NewFragmentBufferWithOptions(options.FragmentBuffer),
+		sequencer: NewSequencer(options.Sequencer),
+		lastSeen:  make(map[ConnectionKey]time.Time),
+	}
+
+	s.sweepInterval = options.SessionTimeout
+
+	for _, timeout := range []time.Duration{options.Sequencer.GapTimeout, options.FragmentBuffer.Timeout} {
+		if timeout > 0 && timeout < s.sweepInterval {
+			s.sweepInterval = timeout
+		}
+	}
+
+	s.sweepInterval /= streamSweepDivisor
+
+	s.sessions = NewSessionTracker(SessionTrackerOptions{
+		Timeout:        options.SessionTimeout,
+		OnEvent:        options.OnSession,
+		FragmentBuffer: s.fragments,
+		Sequencer:      s.sequencer,
+		OnRelease: func(connection ConnectionKey, commands []PhotonCommand) {
+			for _, command := range commands {
+				s.released = s.handle(s.released, connection, s.now, command)
+			}
+		},
+	})
+
+	return s
+}
+
+// Calls the handler with each message decoded from the source, returning once
+// the source is exhausted.
+func (s *Stream) Run(handler func(Message)) {
+	for packet := range s.source.Packets() {
+		for _, msg := range s.Process(packet) {
+			handler(msg)
+		}
+	}
+}
+
+// Returns a channel of the messages decoded from the source, which is closed
+// once the source is exhausted.
+func (s *Stream) Messages() <-chan Message {
+	messages := make(chan Message, 1000)
+
+	go func() {
+		defer close(messages)
+
+		s.Run(func(msg Message) {
+			messages <- msg
+		})
+	}()
+
+	return messages
+}
+
+// Decodes a packet, returning the messages it completes. Packets without a
+// PhotonLayer return nil. Packets must be processed in order of capture.
+func (s *Stream) Process(packet gopacket.Packet) []Message {
+	layer, ok := packet.Layer(PhotonLayerType).(PhotonLayer)
+
+	if !ok {
+		return nil
+	}
+
+	now := packet.Metadata().Timestamp
+	connection := NewConnectionKey(packet, layer.PeerID)
+
+	var messages []Message
+
+	s.now = now
+	s.lastSeen[connection] = now
+
+	if now.Before(s.nextSweep) {
+		// Between checks for timeouts only the time is kept current
+		s.fragments.now = now
+		s.sequencer.now = now
+		s.sessions.now = now
+	} else {
+		s.nextSweep = now.Add(s.sweepInterval)
+		s.fragments.Advance(now)
+		s.sequencer.advance(now, func(released ConnectionKey, commands []PhotonCommand) {
+			for _, command := range commands {
+				messages = s.handle(messages, released, now, command)
+			}
+		})
+		s.sessions.Advance(now)
+		s.expire(now)
+	}
+
+	for _, command := range layer.Commands {
+		// Disconnect ends the session once the commands before it are
+		// sequenced, freeing state which sequencing anything after it would
+		// recreate
+		if command.Type == DisconnectType {
+			break
+		}
+
+		for _, ordered := range s.sequencer.Offer(connection, command) {
+			messages = s.handle(messages, connection, now, ordered)
+		}
+	}
+
+	s.sessions.Observe(connection, layer)
+
+	messages = append(messages, s.released...)
+	s.released = s.released[:0]
+
+	return messages
+}
+
+// Drops the state of connections which haven't sent a packet within the
+// session timeout. Connections of tracked sessions are freed when their
+// session ends instead.
+func (s *Stream) expire(now time.Time) {
+	for connection, lastSeen := range s.lastSeen {
+		if now.Sub(lastSeen) <= s.options.SessionTimeout {
+			continue
+		}
+
+		delete(s.lastSeen, connection)
+
+		if session, _ := s.sessions.lookup(connection); session == nil {
+			s.fragments.Remove(connection)
+			s.sequencer.Remove(connection)
+		}
+	}
+}
+
+// Appends the message carried by a command once it's been put in order.
+func (s *Stream) handle(messages []Message, connection ConnectionKey, now time.Time, command PhotonCommand) []Message {
+	commandType := command.Type
+
+	switch command.Type {
+	case SendReliableFragmentType:
+		assembled, err := s.fragments.OfferCommand(connection, command)
+
+		if err != nil {
+			s.error(err)
+		}
+
+		if assembled == nil {
+			return messages
+		}
+
+		command = *assembled
+	case SendReliableType, SendUnreliableType:
+	default:
+		return messages
+	}
+
+	header, err := command.ReliableMessageWithProtocol(s.options.Protocol)
+
+	if err != nil {
+		s.error(err)
+		return messages
+	}
+
+	params, err := DecodeReliableMessage(header)
+
+	if err != nil {
+		s.error(fmt.Errorf("Message on channel %d: %s", command.ChannelID, err.Error()))
+		return messages
+	}
+
+	return append(messages, Message{
+		Connection:  connection,
+		Direction:   s.direction(connection),
+		Time:        now,
+		ChannelID:   command.ChannelID,
+		CommandType: commandType,
+		Header:      header,
+		Paramaters:  params,
+	})
+}
+
+// Returns which side of its session sent on a connection, falling back to
+// which side's port is a server port when the session isn't tracked.
+func (s *Stream) direction(connection ConnectionKey) Direction {
+	if session, fromClient := s.sessions.lookup(connection); session != nil {
+		if fromClient {
+			return DirectionToServer
+		}
+
+		return DirectionToClient
+	}
+
+	src, dst := connection.Transport.Endpoints()
+
+	switch {
+	case s.isServerPort(dst):
+		return DirectionToServer
+	case s.isServerPort(src):
+		return DirectionToClient
+	default:
+		return DirectionUnknown
+	}
+}
+
+func (s *Stream) isServerPort(endpoint gopacket.Endpoint) bool {
+	raw := endpoint.Raw()
+
+	if len(raw) != 2 {
+		return false
+	}
+
+	port := binary.BigEndian.Uint16(raw)
+
+	for _, serverPort := range s.options.ServerPorts {
+		if port == serverPort {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Stream) error(err error) {
+	if s.options.OnError != nil {
+		s.options.OnError(err)
+	}
+}
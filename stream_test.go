@@ -0,0 +1,242 @@
+package photon_spectator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func streamPacket(t testing.TB, fromClient bool, at time.Time, commands ...PhotonCommand) gopacket.Packet {
+	return peerPacket(t, fromClient, 0, at, commands...)
+}
+
+func peerPacket(t testing.TB, fromClient bool, peerID uint16, at time.Time, commands ...PhotonCommand) gopacket.Packet {
+	RegisterUDPPorts()
+
+	ip := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	udp := layers.UDP{SrcPort: 50000, DstPort: 5056}
+
+	if !fromClient {
+		ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+		udp.SrcPort, udp.DstPort = udp.DstPort, udp.SrcPort
+	}
+
+	udp.SetNetworkLayerForChecksum(&ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+
+	if err := gopacket.SerializeLayers(buf, opts, &ip, &udp, PhotonLayer{PeerID: peerID, Commands: commands}); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	packet := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	packet.Metadata().Timestamp = at
+
+	return packet
+}
+
+func fragmentCommand(sequenceNumber int32, start int32, number int32, offset int32, total int32, data []byte) PhotonCommand {
+	buf := new(bytes.Buffer)
+
+	binary.Write(buf, binary.BigEndian, []int32{start, 2, number, total, offset})
+	buf.Write(data)
+
+	return PhotonCommand{
+		Type:                   SendReliableFragmentType,
+		Flags:                  ReliableFlag,
+		ReliableSequenceNumber: sequenceNumber,
+		Data:                   buf.Bytes(),
+	}
+}
+
+func TestStream_Process(t *testing.T) {
+	var failures []error
+	stream := NewStream(nil, StreamOptions{OnError: func(err error) { failures = append(failures, err) }})
+	start := time.Unix(1000, 0)
+
+	request, _ := EncodeReliableMessage(ReliableMessage{Signature: 0xf3, Type: OperationRequest, OperationCode: 9},
+		ReliableMessageParamaters{"1": "a fragmented request"})
+	event, _ := EncodeReliableMessage(ReliableMessage{Signature: 0xf3, Type: EventDataType, EventCode: 4},
+		ReliableMessageParamaters{"2": int32(12)})
+
+	half := len(request) / 2
+	total := int32(len(request))
+
+	var messages []Message
+
+	packets := []gopacket.Packet{
+		streamPacket(t, true, start, PhotonCommand{Type: ConnectType, Flags: ReliableFlag, ReliableSequenceNumber: 1}),
+		streamPacket(t, false, start, PhotonCommand{Type: VerifyConnectType, Flags: ReliableFlag, ReliableSequenceNumber: 1, Data: []byte{0x00, 0x07}}),
+		streamPacket(t, true, start.Add(time.Second), fragmentCommand(3, 2, 1, int32(half), total, request[half:])),
+		streamPacket(t, true, start.Add(2*time.Second), fragmentCommand(2, 2, 0, 0, total, request[:half])),
+		streamPacket(t, true, start.Add(3*time.Second), fragmentCommand(2, 2, 0, 0, total, request[:half])),
+		streamPacket(t, false, start.Add(4*time.Second), PhotonCommand{Type: SendUnreliableType, Data: append([]byte{0x00, 0x00, 0x00, 0x01}, event...)}),
+	}
+
+	for _, packet := range packets {
+		messages = append(messages, stream.Process(packet)...)
+	}
+
+	if len(failures) != 0 {
+		t.Fatalf("Unexpected errors %v", failures)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages but got %d", len(messages))
+	}
+
+	if messages[0].Direction != DirectionToServer || messages[0].Header.OperationCode != 9 {
+		t.Errorf("Request invalid: %#v", messages[0])
+	}
+
+	if messages[0].CommandType != SendReliableFragmentType {
+		t.Errorf("Expected the request to be reassembled")
+	}
+
+	if !messages[0].Time.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("Expected the request at the time of its last fragment")
+	}
+
+	if !reflect.DeepEqual(messages[0].Paramaters, ReliableMessageParamaters{"1": "a fragmented request"}) {
+		t.Errorf("Request paramaters invalid: %v", messages[0].Paramaters)
+	}
+
+	if messages[1].Direction != DirectionToClient || !messages[1].Header.Unreliable || messages[1].Header.EventCode != 4 {
+		t.Errorf("Event invalid: %#v", messages[1])
+	}
+
+	if !reflect.DeepEqual(messages[1].Paramaters, ReliableMessageParamaters{"2": int32(12)}) {
+		t.Errorf("Event paramaters invalid: %v", messages[1].Paramaters)
+	}
+}
+
+func TestStream_Process_UntrackedDirection(t *testing.T) {
+	stream := NewStream(nil, StreamOptions{})
+	event, _ := EncodeReliableMessage(ReliableMessage{Signature: 0xf3, Type: EventDataType, EventCode: 1}, nil)
+
+	messages := stream.Process(streamPacket(t, false, time.Unix(0, 0), PhotonCommand{Type: SendReliableType, Data: event}))
+
+	if len(messages) != 1 || messages[0].Direction != DirectionToClient {
+		t.Errorf("Expected the direction to come from the server port")
+	}
+}
+
+func TestStream_Process_FreesState(t *testing.T) {
+	stream := NewStream(nil, StreamOptions{})
+	start := time.Unix(1000, 0)
+	event, _ := EncodeReliableMessage(ReliableMessage{Signature: 0xf3, Type: EventDataType, EventCode: 1}, nil)
+
+	// A connection whose Connect wasn't seen is dropped once it's quiet
+	stream.Process(streamPacket(t, false, start, PhotonCommand{Type: SendReliableType, Flags: ReliableFlag, ReliableSequenceNumber: 1, Data: event}))
+	stream.Process(streamPacket(t, false, start, PhotonCommand{Type: SendReliableType, Flags: ReliableFlag, ReliableSequenceNumber: 3, Data: event}))
+
+	if len(stream.sequencer.channels) != 1 {
+		t.Fatalf("Expected the connection to be sequenced")
+	}
+
+	stream.Process(streamPacket(t, true, start.Add(DefaultSessionTimeout+time.Second)))
+
+	if len(stream.sequencer.channels) != 0 || len(stream.lastSeen) != 1 {
+		t.Errorf("Expected the quiet connection to be dropped")
+	}
+
+	// Commands before a Disconnect are sequenced before the session frees
+	// the connection
+	stream = NewStream(nil, StreamOptions{})
+	stream.Process(streamPacket(t, true, start, PhotonCommand{Type: ConnectType, ChannelID: 0xff, Flags: ReliableFlag, ReliableSequenceNumber: 1}))
+
+	messages := stream.Process(streamPacket(t, true, start,
+		PhotonCommand{Type: SendReliableType, Flags: ReliableFlag, ReliableSequenceNumber: 1, Data: event},
+		PhotonCommand{Type: DisconnectType, ChannelID: 0xff, Flags: ReliableFlag, ReliableSequenceNumber: 2}))
+
+	if len(messages) != 1 {
+		t.Errorf("Expected the message before the Disconnect to be delivered")
+	}
+
+	if len(stream.sequencer.channels) != 0 || len(stream.sessions.Sessions()) != 0 {
+		t.Errorf("Expected Disconnect to leave no sequencer state")
+	}
+}
+
+func TestStream_Process_ReleasesOnDisconnect(t *testing.T) {
+	stream := NewStream(nil, StreamOptions{})
+	start := time.Unix(1000, 0)
+	event, _ := EncodeReliableMessage(ReliableMessage{Signature: 0xf3, Type: EventDataType, EventCode: 3}, nil)
+
+	stream.Process(streamPacket(t, true, start, PhotonCommand{Type: ConnectType, ChannelID: 0xff, Flags: ReliableFlag, ReliableSequenceNumber: 1}))
+	stream.Process(streamPacket(t, false, start, PhotonCommand{Type: SendReliableType, Flags: ReliableFlag, ReliableSequenceNumber: 1, Data: event}))
+
+	if messages := stream.Process(streamPacket(t, false, start, PhotonCommand{Type: SendReliableType, Flags: ReliableFlag, ReliableSequenceNumber: 3, Data: event})); len(messages) != 0 {
+		t.Fatalf("Expected the message to be held behind the gap")
+	}
+
+	messages := stream.Process(streamPacket(t, true, start, PhotonCommand{Type: DisconnectType, ChannelID: 0xff, Flags: ReliableFlag, ReliableSequenceNumber: 2}))
+
+	if len(messages) != 1 || messages[0].Header.EventCode != 3 || messages[0].Direction != DirectionToClient {
+		t.Errorf("Expected the held message to be released but got %#v", messages)
+	}
+}
+
+func TestStream_Messages(t *testing.T) {
+	event, _ := EncodeReliableMessage(ReliableMessage{Signature: 0xf3, Type: EventDataType, EventCode: 1}, nil)
+	packet := streamPacket(t, false, time.Unix(0, 0), PhotonCommand{Type: SendReliableType, Data: event})
+
+	source := gopacket.NewPacketSource(&packetList{data: [][]byte{packet.Data(), {0x00}}}, layers.LayerTypeIPv4)
+
+	count := 0
+
+	for msg := range NewStream(source, StreamOptions{}).Messages() {
+		if msg.Header.EventCode != 1 {
+			t.Errorf("Event invalid")
+		}
+
+		count++
+	}
+
+	if count != 1 {
+		t.Errorf("Expected 1 message but got %d", count)
+	}
+}
+
+func BenchmarkStream_Process(b *testing.B) {
+	stream := NewStream(nil, StreamOptions{})
+	start := time.Unix(1000, 0)
+
+	// Keep many connections live so that work per connection would show
+	for i := 0; i < 10000; i++ {
+		stream.Process(peerPacket(b, true, uint16(i), start, reliableCommand(1)))
+	}
+
+	packet := peerPacket(b, true, 0, start, PhotonCommand{Type: SendUnreliableType})
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		packet.Metadata().Timestamp = start.Add(time.Duration(i) * time.Microsecond)
+		stream.Process(packet)
+	}
+}
+
+// Supplies packets to a PacketSource from memory.
+type packetList struct {
+	data [][]byte
+}
+
+func (l *packetList) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	if len(l.data) == 0 {
+		return nil, gopacket.CaptureInfo{}, io.EOF
+	}
+
+	data := l.data[0]
+	l.data = l.data[1:]
+
+	return data, gopacket.CaptureInfo{CaptureLength: len(data), Length: len(data)}, nil
+}
@@ -0,0 +1,224 @@
+package photon_spectator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Describes a paramater whose value can't be stored in the field tagged with
+// its ID.
+type UnmarshalTypeError struct {
+	// ID of the paramater
+	Paramater string
+	// Path of the field within the target, such as "Items[2]"
+	Field string
+	// Type of the value which couldn't be stored
+	Value reflect.Type
+	// Type of the field
+	Type reflect.Type
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("Paramater %s can't be stored in %s: %s is not convertible to %s", e.Paramater, e.Field, e.Value, e.Type)
+}
+
+// Stores the paramaters of a message in the fields of the struct v points to,
+// following the paramater ID in each field's `photon` tag:
+//
+//	type Move struct {
+//		Position []float32 `photon:"1"`
+//		Speed    float64   `photon:"2"`
+//		Target   *int64    `photon:"3"`
+//		Name     string    `photon:"4,required"`
+//	}
+//
+// Numbers are widened to larger types of the same sign, or to floats able to
+// represent them exactly, but never narrowed. Slices and maps are converted
+// element by element, and nested events, requests, responses and paramaters
+// are stored in nested structs. Fields whose paramater is missing or nil are
+// left untouched, unless tagged required. Untagged fields are ignored.
+func Unmarshal(params ReliableMessageParamaters, v interface{}) error {
+	target := reflect.ValueOf(v)
+
+	if target.Kind() != reflect.Ptr || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Unmarshal target must be a pointer to a struct, not %T", v)
+	}
+
+	return unmarshalStruct(params, target.Elem(), "")
+}
+
+func unmarshalStruct(params ReliableMessageParamaters, target reflect.Value, path string) error {
+	structType := target.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag, ok := field.Tag.Lookup("photon")
+
+		if !ok || tag == "-" {
+			continue
+		}
+
+		options := strings.Split(tag, ",")
+		id, err := strconv.Atoi(options[0])
+
+		if err != nil || id < 0 || id > 255 {
+			return fmt.Errorf("Invalid photon tag of %q on field %s", tag, path+field.Name)
+		}
+
+		if field.PkgPath != "" {
+			return fmt.Errorf("Field %s is tagged but not exported", path+field.Name)
+		}
+
+		value, ok := params[options[0]]
+
+		if !ok || value == nil {
+			for _, option := range options[1:] {
+				if option == "required" {
+					return fmt.Errorf("Paramater %s of field %s is missing", options[0], path+field.Name)
+				}
+			}
+
+			continue
+		}
+
+		if err := assign(target.Field(i), value, options[0], path+field.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stores a decoded value in the target, converting it to the target's type.
+func assign(target reflect.Value, value interface{}, paramater string, path string) error {
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+
+	source := reflect.ValueOf(value)
+	mismatch := &UnmarshalTypeError{paramater, path, source.Type(), target.Type()}
+
+	if source.Type().AssignableTo(target.Type()) {
+		target.Set(source)
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Ptr:
+		element := reflect.New(target.Type().Elem())
+
+		if err := assign(element.Elem(), value, paramater, path); err != nil {
+			return err
+		}
+
+		target.Set(element)
+		return nil
+	case reflect.Struct:
+		switch nested := value.(type) {
+		case ReliableMessageParamaters:
+			return unmarshalStruct(nested, target, path+".")
+		case map[string]interface{}:
+			return unmarshalStruct(nested, target, path+".")
+		case EventDataValue:
+			return unmarshalStruct(nested.Paramaters, target, path+".")
+		case OperationRequestValue:
+			return unmarshalStruct(nested.Paramaters, target, path+".")
+		case OperationResponseValue:
+			return unmarshalStruct(nested.Paramaters, target, path+".")
+		}
+	case reflect.Slice:
+		if source.Kind() != reflect.Slice {
+			return mismatch
+		}
+
+		slice := reflect.MakeSlice(target.Type(), source.Len(), source.Len())
+
+		for i := 0; i < source.Len(); i++ {
+			if err := assign(slice.Index(i), source.Index(i).Interface(), paramater, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+
+		target.Set(slice)
+		return nil
+	case reflect.Map:
+		if source.Kind() != reflect.Map {
+			return mismatch
+		}
+
+		mapping := reflect.MakeMapWithSize(target.Type(), source.Len())
+		iter := source.MapRange()
+
+		for iter.Next() {
+			key := reflect.New(target.Type().Key()).Elem()
+			element := reflect.New(target.Type().Elem()).Elem()
+			elementPath := fmt.Sprintf("%s[%v]", path, iter.Key().Interface())
+
+			if err := assign(key, iter.Key().Interface(), paramater, elementPath); err != nil {
+				return err
+			}
+
+			if err := assign(element, iter.Value().Interface(), paramater, elementPath); err != nil {
+				return err
+			}
+
+			mapping.SetMapIndex(key, element)
+		}
+
+		target.Set(mapping)
+		return nil
+	}
+
+	if !widens(source.Type(), target.Type()) {
+		return mismatch
+	}
+
+	target.Set(source.Convert(target.Type()))
+	return nil
+}
+
+// Reports whether every value of the source type is exactly representable in
+// the target type.
+func widens(source reflect.Type, target reflect.Type) bool {
+	switch {
+	case source.Kind() == target.Kind():
+		return source.ConvertibleTo(target)
+	case isSigned(source) && isSigned(target), isUnsigned(source) && isUnsigned(target):
+		return source.Bits() <= target.Bits()
+	case isUnsigned(source) && isSigned(target):
+		return source.Bits() < target.Bits()
+	case isFloat(source) && isFloat(target):
+		return source.Bits() <= target.Bits()
+	case (isSigned(source) || isUnsigned(source)) && target.Kind() == reflect.Float32:
+		return source.Bits() <= 16
+	case (isSigned(source) || isUnsigned(source)) && target.Kind() == reflect.Float64:
+		return source.Bits() <= 32
+	default:
+		return false
+	}
+}
+
+func isSigned(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isUnsigned(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isFloat(t reflect.Type) bool {
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
@@ -0,0 +1,110 @@
+package photon_spectator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type unmarshalNested struct {
+	Count int32 `photon:"1"`
+}
+
+type unmarshalTarget struct {
+	Widened  int                   `photon:"0"`
+	Int32    int32                 `photon:"1"`
+	Float    float64               `photon:"2"`
+	Name     string                `photon:"3,required"`
+	Optional *int64                `photon:"4"`
+	Missing  *int64                `photon:"5"`
+	Values   []int64               `photon:"6"`
+	Objects  []string              `photon:"7"`
+	Table    map[string]int32      `photon:"8"`
+	Event    unmarshalNested       `photon:"9"`
+	Any      interface{}           `photon:"10"`
+	Bytes    []byte                `photon:"11"`
+	Nested   []map[uint16]*float64 `photon:"12"`
+	Ignored  int32
+}
+
+func TestUnmarshal(t *testing.T) {
+	params := ReliableMessageParamaters{
+		"0":  int16(-3),
+		"1":  uint8(200),
+		"2":  int32(7),
+		"3":  "name",
+		"4":  int16(4),
+		"6":  []int16{1, -2},
+		"7":  []interface{}{"a", "b"},
+		"8":  map[interface{}]interface{}{"x": int16(1)},
+		"9":  EventDataValue{Code: 1, Paramaters: ReliableMessageParamaters{"1": int16(9)}},
+		"10": []float32{1.5},
+		"11": []uint8{0xca, 0xfe},
+		"12": []map[interface{}]interface{}{{uint8(1): float32(0.5)}},
+	}
+
+	var actual unmarshalTarget
+
+	if err := Unmarshal(params, &actual); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	optional := int64(4)
+	half := float64(0.5)
+
+	expected := unmarshalTarget{
+		Widened:  -3,
+		Int32:    200,
+		Float:    7,
+		Name:     "name",
+		Optional: &optional,
+		Values:   []int64{1, -2},
+		Objects:  []string{"a", "b"},
+		Table:    map[string]int32{"x": 1},
+		Event:    unmarshalNested{Count: 9},
+		Any:      []float32{1.5},
+		Bytes:    []byte{0xca, 0xfe},
+		Nested:   []map[uint16]*float64{{1: &half}},
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %+v but got %+v", expected, actual)
+	}
+}
+
+func TestUnmarshal_Errors(t *testing.T) {
+	mismatches := []struct {
+		input  ReliableMessageParamaters
+		output string
+	}{
+		{ReliableMessageParamaters{}, "Paramater 3 of field Name is missing"},
+		{ReliableMessageParamaters{"3": "", "1": int64(1)}, "Paramater 1 can't be stored in Int32: int64 is not convertible to int32"},
+		{ReliableMessageParamaters{"3": "", "1": int32(-1), "0": "1"}, "Paramater 0 can't be stored in Widened: string is not convertible to int"},
+		{ReliableMessageParamaters{"3": "", "6": []interface{}{int64(1), "2"}}, "Paramater 6 can't be stored in Values[1]: string is not convertible to int64"},
+		{ReliableMessageParamaters{"3": "", "9": EventDataValue{Paramaters: ReliableMessageParamaters{"1": 1.5}}}, "Paramater 1 can't be stored in Event.Count: float64 is not convertible to int32"},
+		{ReliableMessageParamaters{"3": "", "2": int64(1)}, "Paramater 2 can't be stored in Float: int64 is not convertible to float64"},
+	}
+
+	for _, e := range mismatches {
+		var target unmarshalTarget
+		err := Unmarshal(e.input, &target)
+
+		if err == nil || err.Error() != e.output {
+			t.Errorf("Expected %q but got %v", e.output, err)
+		}
+	}
+
+	var target unmarshalTarget
+
+	if err := Unmarshal(ReliableMessageParamaters{}, target); err == nil || !strings.Contains(err.Error(), "pointer to a struct") {
+		t.Errorf("Expected non-pointer targets to error")
+	}
+
+	var invalid struct {
+		Field int `photon:"256"`
+	}
+
+	if err := Unmarshal(ReliableMessageParamaters{}, &invalid); err == nil {
+		t.Errorf("Expected invalid tags to error")
+	}
+}